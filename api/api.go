@@ -0,0 +1,100 @@
+/* Package api builds the versioned, machine-readable streamer list other sites can consume. */
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/infosecstreams/secinfo/output"
+	"github.com/infosecstreams/secinfo/render"
+	"github.com/infosecstreams/secinfo/streamers"
+)
+
+// Version is the schema version of List. It's bumped, along with the output directory,
+// whenever a field is removed or changes meaning. New fields don't bump it.
+const Version = 1
+
+// Schema is the JSON Schema List output is validated against. It's published next to the output.
+//
+//go:embed schema/v1.json
+var Schema []byte
+
+// List is the document written to api/v1/streamers.json.
+type List struct {
+	Version     int        `json:"version"`      // The schema version, always Version
+	GeneratedAt time.Time  `json:"generated_at"` // When the list was generated, in UTC
+	WindowDays  int        `json:"window_days"`  // Days of activity hours_streamed covers
+	Active      []Streamer `json:"active"`       // Active streamers, ranked by hours streamed
+	Inactive    []Streamer `json:"inactive"`     // Inactive streamers, sorted by name
+}
+
+// Streamer is a single streamer in a List.
+type Streamer struct {
+	Name          string    `json:"name"`               // The streamer's display name
+	Rank          int       `json:"rank,omitempty"`     // 1-based position on the active list, omitted when inactive
+	Active        bool      `json:"active"`             // Whether the streamer is on the active list
+	Online        bool      `json:"online"`             // Whether the streamer was live when the list was generated
+	HoursStreamed float32   `json:"hours_streamed"`     // Hours streamed in the last window_days, across platforms
+	Language      string    `json:"language,omitempty"` // The streamer's language as a BCP 47 tag, when known
+	Tags          []string  `json:"tags,omitempty"`     // Topics the streamer covers, e.g. "red-team"
+	Platforms     []Account `json:"platforms"`          // One entry per platform the streamer is on
+}
+
+// Account is a streamer's presence on one platform.
+type Account struct {
+	Platform string `json:"platform"` // One of twitch, youtube, kick, owncast, peertube
+	Handle   string `json:"handle"`   // The account's handle on the platform
+	URL      string `json:"url"`      // The public url of the account
+}
+
+// NewList converts the rendered active and inactive pages into a List.
+func NewList(active, inactive render.Page, generatedAt time.Time) List {
+	l := List{
+		Version:     Version,
+		GeneratedAt: generatedAt.UTC(),
+		WindowDays:  streamers.WindowDays,
+		Active:      make([]Streamer, 0, len(active.Streamers)),
+		Inactive:    make([]Streamer, 0, len(inactive.Streamers)),
+	}
+	for i, row := range active.Streamers {
+		s := newStreamer(row)
+		s.Rank, s.Active = i+1, true
+		l.Active = append(l.Active, s)
+	}
+	for _, row := range inactive.Streamers {
+		l.Inactive = append(l.Inactive, newStreamer(row))
+	}
+	return l
+}
+
+func newStreamer(row render.Row) Streamer {
+	s := Streamer{
+		Name:          row.Name,
+		Online:        row.Online,
+		HoursStreamed: row.ThirtyDayStats,
+		Language:      row.Lang,
+		Platforms:     make([]Account, 0, len(row.Accounts)),
+	}
+	for _, t := range row.AllTags() {
+		s.Tags = append(s.Tags, string(t))
+	}
+	for _, a := range row.Accounts {
+		s.Platforms = append(s.Platforms, Account{Platform: string(a.Platform), Handle: a.Handle, URL: a.URL})
+	}
+	return s
+}
+
+// Render queues the list as dir/v1/streamers.json into b, with its schema next to it as streamers.schema.json.
+func Render(b *output.Batch, dir string, l List) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling api list: %w", err)
+	}
+	versionDir := path.Join(dir, fmt.Sprintf("v%d", Version))
+	b.Add(path.Join(versionDir, "streamers.json"), append(data, '\n'))
+	b.Add(path.Join(versionDir, "streamers.schema.json"), Schema)
+	return nil
+}
@@ -0,0 +1,226 @@
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/infosecstreams/secinfo/api"
+	"github.com/infosecstreams/secinfo/output"
+	"github.com/infosecstreams/secinfo/render"
+	"github.com/infosecstreams/secinfo/streamers"
+)
+
+func testList(t *testing.T) (api.List, map[string][]byte) {
+	t.Helper()
+
+	alice := streamers.Streamer{Name: "alice", ThirtyDayStats: 12.5, Lang: "en", Online: true}
+	alice.SetAccount(streamers.Account{Platform: streamers.Twitch, Handle: "alice"})
+	alice.SetAccount(streamers.Account{Platform: streamers.Owncast, URL: "https://live.example.com"})
+	bob := streamers.Streamer{Name: "bob", ThirtyDayStats: 3, Lang: "de", Tags: []streamers.Tag{streamers.BlueTeam}}
+	bob.SetAccount(streamers.Account{Platform: streamers.Twitch, Handle: "bob"})
+	carol := streamers.Streamer{Name: "carol"}
+	carol.SetAccount(streamers.Account{Platform: streamers.Twitch, Handle: "carol"})
+	carol.SetAccount(streamers.Account{Platform: streamers.YouTube, URL: "https://www.youtube.com/channel/UC123"})
+
+	active := render.NewPage(streamers.StreamerList{Streamers: []streamers.Streamer{alice, bob}})
+	inactive := render.NewPage(streamers.StreamerList{Streamers: []streamers.Streamer{carol}})
+	l := api.NewList(active, inactive, time.Date(2026, 10, 19, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60)))
+
+	var b output.Batch
+	if err := api.Render(&b, "api", l); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	files := map[string][]byte{}
+	b.Each(func(path string, data []byte) { files[path] = data })
+	return l, files
+}
+
+func TestNewList(t *testing.T) {
+	l, _ := testList(t)
+
+	if l.Version != api.Version || l.WindowDays != streamers.WindowDays {
+		t.Errorf("Got: version %d window %d", l.Version, l.WindowDays)
+	}
+	if got := l.GeneratedAt.Format(time.RFC3339); got != "2026-10-19T10:00:00Z" {
+		t.Errorf("generated_at should be UTC, Got: %s", got)
+	}
+	if l.Active[0].Rank != 1 || l.Active[1].Rank != 2 || l.Inactive[0].Rank != 0 {
+		t.Errorf("ranks are wrong: %+v", l)
+	}
+	if l.Active[0].Language != "en" || l.Active[1].Language != "de" || l.Inactive[0].Language != "" {
+		t.Errorf("language should be set whenever it's known: %+v", l)
+	}
+	if len(l.Active[0].Tags) != 0 || len(l.Active[1].Tags) != 1 || l.Active[1].Tags[0] != "blue-team" {
+		t.Errorf("Got: %q and %q, Wanted no tags and [blue-team]", l.Active[0].Tags, l.Active[1].Tags)
+	}
+	if l.Inactive[0].Active || len(l.Inactive[0].Platforms) != 2 {
+		t.Errorf("inactive streamer is wrong: %+v", l.Inactive[0])
+	}
+}
+
+func TestRenderMatchesSchema(t *testing.T) {
+	_, files := testList(t)
+
+	data, ok := files["api/v1/streamers.json"]
+	if !ok {
+		t.Fatalf("api/v1/streamers.json not rendered")
+	}
+	if string(files["api/v1/streamers.schema.json"]) != string(api.Schema) {
+		t.Fatalf("schema not published next to the output")
+	}
+
+	var schema, doc any
+	if err := json.Unmarshal(api.Schema, &schema); err != nil {
+		t.Fatalf("schema is not json: %v", err)
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("output is not json: %v", err)
+	}
+	v := validator{root: schema.(map[string]any)}
+	if errs := v.validate(v.root, doc, "$"); len(errs) > 0 {
+		t.Fatalf("output doesn't match schema:\n%s", strings.Join(errs, "\n"))
+	}
+
+	// Make sure the validator actually rejects things
+	doc.(map[string]any)["active"].([]any)[0].(map[string]any)["YTURL"] = ""
+	delete(doc.(map[string]any), "generated_at")
+	if errs := v.validate(v.root, doc, "$"); len(errs) != 2 {
+		t.Fatalf("validator should find 2 errors, Got: %q", errs)
+	}
+}
+
+func TestSchemaPlatforms(t *testing.T) {
+	var schema struct {
+		Defs struct {
+			Account struct {
+				Properties struct {
+					Platform struct {
+						Enum []string `json:"enum"`
+					} `json:"platform"`
+				} `json:"properties"`
+			} `json:"account"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(api.Schema, &schema); err != nil {
+		t.Fatalf("schema is not json: %v", err)
+	}
+	var want []string
+	for _, p := range streamers.Platforms {
+		want = append(want, string(p))
+	}
+	if got := schema.Defs.Account.Properties.Platform.Enum; !reflect.DeepEqual(got, want) {
+		t.Fatalf("schema platforms Got: %v, Wanted: %v", got, want)
+	}
+}
+
+// validator checks a document against the subset of JSON Schema the published schema uses.
+type validator struct {
+	root map[string]any
+}
+
+func (v validator) validate(schema map[string]any, value any, at string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		def := v.root
+		for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			def = def[part].(map[string]any)
+		}
+		return v.validate(def, value, at)
+	}
+
+	var errs []string
+	fail := func(format string, args ...any) {
+		errs = append(errs, at+": "+fmt.Sprintf(format, args...))
+	}
+
+	if want, ok := schema["const"]; ok && !reflect.DeepEqual(want, value) {
+		fail("Got: %v, Wanted: %v", value, want)
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			found = found || reflect.DeepEqual(e, value)
+		}
+		if !found {
+			fail("%v is not one of %v", value, enum)
+		}
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			fail("not an object")
+			break
+		}
+		props, _ := schema["properties"].(map[string]any)
+		for _, req := range schema["required"].([]any) {
+			if _, ok := obj[req.(string)]; !ok {
+				fail("missing %s", req)
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			prop, ok := props[k].(map[string]any)
+			if !ok {
+				if schema["additionalProperties"] == false {
+					fail("unexpected property %s", k)
+				}
+				continue
+			}
+			errs = append(errs, v.validate(prop, obj[k], at+"."+k)...)
+		}
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			fail("not an array")
+			break
+		}
+		for i, item := range arr {
+			errs = append(errs, v.validate(schema["items"].(map[string]any), item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			fail("not a string")
+			break
+		}
+		if min, ok := schema["minLength"].(float64); ok && float64(len(s)) < min {
+			fail("shorter than %v", min)
+		}
+		switch schema["format"] {
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				fail("not a date-time: %v", err)
+			}
+		case "uri":
+			if u, err := url.Parse(s); err != nil || u.Scheme == "" {
+				fail("not a uri: %s", s)
+			}
+		}
+	case "integer", "number":
+		n, ok := value.(float64)
+		if !ok || schema["type"] == "integer" && n != math.Trunc(n) {
+			fail("not an %s", schema["type"])
+			break
+		}
+		if min, ok := schema["minimum"].(float64); ok && n < min {
+			fail("less than %v", min)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("not a boolean")
+		}
+	}
+	return errs
+}
@@ -0,0 +1,227 @@
+/* Package calendar exports streamers' published schedules as iCalendar (.ics) files. */
+package calendar
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/infosecstreams/secinfo/output"
+	"github.com/infosecstreams/secinfo/streamers"
+	"github.com/spf13/afero"
+)
+
+// Segment is a single scheduled stream.
+type Segment struct {
+	ID       string    // A unique, stable ID for the segment
+	Title    string    // The stream's title
+	Category string    // The game or category the stream is scheduled under
+	Start    time.Time // When the stream starts
+	End      time.Time // When the stream ends
+	Canceled bool      // Whether this occurrence was canceled
+}
+
+// ScheduleProvider returns the upcoming segments a streamer has published.
+type ScheduleProvider interface {
+	Schedule(ctx context.Context, s streamers.Streamer) ([]Segment, error)
+}
+
+// Schedule is a streamer and their upcoming segments.
+type Schedule struct {
+	Streamer streamers.Streamer
+	Segments []Segment
+}
+
+// Failure is a streamer whose schedule couldn't be fetched.
+type Failure struct {
+	Streamer streamers.Streamer
+	Err      error
+}
+
+// Fetch asks p for the schedule of every streamer in sl. Streamers without any segments are left out.
+// Failed lookups are returned per streamer next to the schedules that were found.
+// Once ctx is done the remaining streamers aren't asked for and fail with its error.
+func Fetch(ctx context.Context, p ScheduleProvider, sl []streamers.Streamer) ([]Schedule, []Failure) {
+	var schedules []Schedule
+	var failures []Failure
+	for _, s := range sl {
+		if err := ctx.Err(); err != nil {
+			failures = append(failures, Failure{Streamer: s, Err: err})
+			continue
+		}
+		segments, err := p.Schedule(ctx, s)
+		if err != nil {
+			failures = append(failures, Failure{Streamer: s, Err: err})
+			continue
+		}
+		if len(segments) > 0 {
+			schedules = append(schedules, Schedule{Streamer: s, Segments: segments})
+		}
+	}
+	return schedules, failures
+}
+
+// Keep adds the schedules the streamers that failed had in the last calendars Render wrote to dir,
+// so a failed lookup doesn't drop their streams. Streamers without a calendar in dir are left out.
+func Keep(fileSystem afero.Fs, dir string, schedules []Schedule, failures []Failure) ([]Schedule, error) {
+	for _, f := range failures {
+		data, err := afero.ReadFile(fileSystem, path.Join(dir, streamers.Slug(f.Streamer.Name)+".ics"))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return schedules, err
+		}
+		if segments := Segments(data, f.Streamer.Name); len(segments) > 0 {
+			schedules = append(schedules, Schedule{Streamer: f.Streamer, Segments: segments})
+		}
+	}
+	return schedules, nil
+}
+
+// Render queues dir/streams.ics, a calendar of every schedule named title, into b, plus dir/<slug>.ics per streamer.
+// Event UIDs end in @domain, the list site's host.
+func Render(b *output.Batch, dir, title, domain string, schedules []Schedule) {
+	b.Add(path.Join(dir, "streams.ics"), ICS(title, domain, schedules))
+	for _, s := range schedules {
+		b.Add(path.Join(dir, streamers.Slug(s.Streamer.Name)+".ics"), ICS(s.Streamer.Name+" on "+title, domain, []Schedule{s}))
+	}
+}
+
+// ICS returns an iCalendar named name with an event per segment, sorted by start time, and UIDs ending in @domain.
+// Every event is stamped with its segment's start, so the same schedules always give the same calendar.
+func ICS(name, domain string, schedules []Schedule) []byte {
+	type event struct {
+		streamer streamers.Streamer
+		segment  Segment
+	}
+	var events []event
+	for _, s := range schedules {
+		for _, seg := range s.Segments {
+			events = append(events, event{s.Streamer, seg})
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].segment.Start.Before(events[j].segment.Start)
+	})
+
+	var w icsWriter
+	w.line("BEGIN", "VCALENDAR")
+	w.line("VERSION", "2.0")
+	w.line("PRODID", "-//InfoSec Streams//secinfo//EN")
+	w.line("CALSCALE", "GREGORIAN")
+	w.line("METHOD", "PUBLISH")
+	w.line("X-WR-CALNAME", escape(name))
+	for _, e := range events {
+		summary := e.streamer.Name
+		if e.segment.Title != "" {
+			summary += ": " + e.segment.Title
+		}
+		link := ""
+		if a, ok := e.streamer.Account(streamers.Twitch); ok {
+			link = a.URL
+		}
+
+		w.line("BEGIN", "VEVENT")
+		w.line("UID", escape(e.segment.ID)+"@"+domain)
+		w.line("DTSTAMP", stamp(e.segment.Start))
+		w.line("DTSTART", stamp(e.segment.Start))
+		if !e.segment.End.IsZero() {
+			w.line("DTEND", stamp(e.segment.End))
+		}
+		w.line("SUMMARY", escape(summary))
+		if e.segment.Category != "" {
+			w.line("CATEGORIES", escape(e.segment.Category))
+		}
+		if link != "" {
+			w.line("URL", link)
+			w.line("LOCATION", link)
+		}
+		if e.segment.Canceled {
+			w.line("STATUS", "CANCELLED")
+		} else {
+			w.line("STATUS", "CONFIRMED")
+		}
+		w.line("END", "VEVENT")
+	}
+	w.line("END", "VCALENDAR")
+	return []byte(w.String())
+}
+
+// Segments reads back the segments of a calendar ICS wrote for the streamer called name.
+// Lines it doesn't know, or can't parse, are skipped.
+func Segments(data []byte, name string) []Segment {
+	unfolded := strings.ReplaceAll(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n ", "")
+	var segments []Segment
+	var seg *Segment
+	for _, line := range strings.Split(unfolded, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch {
+		case key == "BEGIN" && value == "VEVENT":
+			seg = &Segment{}
+		case seg == nil:
+		case key == "END" && value == "VEVENT":
+			segments = append(segments, *seg)
+			seg = nil
+		case key == "UID":
+			if at := strings.LastIndex(value, "@"); at >= 0 {
+				value = value[:at]
+			}
+			seg.ID = unescape(value)
+		case key == "DTSTART":
+			seg.Start, _ = time.Parse("20060102T150405Z", value)
+		case key == "DTEND":
+			seg.End, _ = time.Parse("20060102T150405Z", value)
+		case key == "SUMMARY":
+			summary := unescape(value)
+			if summary != name {
+				seg.Title = strings.TrimPrefix(summary, name+": ")
+			}
+		case key == "CATEGORIES":
+			seg.Category = unescape(value)
+		case key == "STATUS":
+			seg.Canceled = value == "CANCELLED"
+		}
+	}
+	return segments
+}
+
+func stamp(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// escape escapes a TEXT value per RFC 5545 section 3.3.11.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// unescape reverses escape.
+func unescape(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(s)
+}
+
+// icsWriter writes content lines folded at 75 octets with CRLF line endings, per RFC 5545 section 3.1.
+type icsWriter struct {
+	strings.Builder
+}
+
+func (w *icsWriter) line(name, value string) {
+	line := name + ":" + value
+	for len(line) > 75 {
+		cut := 75
+		// Don't split a multi-byte character across lines
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.WriteString(line[:cut] + "\r\n")
+		line = " " + line[cut:]
+	}
+	w.WriteString(line + "\r\n")
+}
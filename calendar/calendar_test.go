@@ -0,0 +1,162 @@
+package calendar_test
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/infosecstreams/secinfo/calendar"
+	"github.com/infosecstreams/secinfo/output"
+	"github.com/infosecstreams/secinfo/streamers"
+	"github.com/spf13/afero"
+)
+
+var update = flag.Bool("update", false, "update the golden .ics files in testdata")
+
+// fakeProvider serves schedules from a map, failing for anyone in errs.
+type fakeProvider struct {
+	schedules map[string][]calendar.Segment
+	errs      map[string]error
+}
+
+func (p fakeProvider) Schedule(ctx context.Context, s streamers.Streamer) ([]calendar.Segment, error) {
+	return p.schedules[s.Name], p.errs[s.Name]
+}
+
+func streamer(name string) streamers.Streamer {
+	s := streamers.Streamer{Name: name}
+	s.SetAccount(streamers.Account{Platform: streamers.Twitch, Handle: name})
+	return s
+}
+
+func TestRenderGolden(t *testing.T) {
+	start := time.Date(2026, 10, 20, 18, 0, 0, 0, time.UTC)
+	p := fakeProvider{
+		schedules: map[string][]calendar.Segment{
+			"alice": {
+				{ID: "seg-a2", Title: "Malware Monday, part 2; unpacking", Category: "Science & Technology", Start: start.Add(7 * 24 * time.Hour), End: start.Add(7*24*time.Hour + 2*time.Hour)},
+				{ID: "seg-a1", Title: "Malware Monday", Category: "Science & Technology", Start: start, End: start.Add(2 * time.Hour)},
+			},
+			"bob": {
+				{ID: "seg-b1", Title: "A really long title that definitely needs to be folded onto a second line to stay valid 🔐", Start: start.Add(time.Hour), Canceled: true},
+			},
+		},
+		errs: map[string]error{"mallory": errors.New("boom")},
+	}
+
+	schedules, failed := calendar.Fetch(context.Background(), p, []streamers.Streamer{streamer("alice"), streamer("bob"), streamer("carol"), streamer("mallory")})
+	if len(failed) != 1 || failed[0].Streamer.Name != "mallory" || failed[0].Err.Error() != "boom" {
+		t.Fatalf("Got: %+v, Wanted mallory to fail with boom", failed)
+	}
+	if len(schedules) != 2 {
+		t.Fatalf("carol has no schedule and mallory failed, Got: %d schedules", len(schedules))
+	}
+
+	var b output.Batch
+	calendar.Render(&b, "calendar", "InfoSec Streams", "infosecstreams.com", schedules)
+	if want := []string{"calendar/streams.ics", "calendar/alice.ics", "calendar/bob.ics"}; !reflect.DeepEqual(b.Paths(), want) {
+		t.Fatalf("Got: %v, Wanted: %v", b.Paths(), want)
+	}
+
+	b.Each(func(path string, data []byte) {
+		golden := filepath.Join("testdata", filepath.Base(path))
+		if *update {
+			if err := os.WriteFile(golden, data, 0644); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+		if string(data) != string(want) {
+			t.Errorf("%s doesn't match %s, run go test -update if the change is intended:\n%s", path, golden, data)
+		}
+	})
+}
+
+func TestKeep(t *testing.T) {
+	start := time.Date(2026, 10, 20, 18, 0, 0, 0, time.UTC)
+	last := []calendar.Segment{
+		{ID: "seg-a1", Title: "Malware Monday, part 2; unpacking", Category: "Science & Technology", Start: start, End: start.Add(2 * time.Hour)},
+		{ID: "seg-a2", Title: "A really long title that definitely needs to be folded onto a second line to stay valid 🔐", Start: start.Add(24 * time.Hour), Canceled: true},
+	}
+	var b output.Batch
+	calendar.Render(&b, "calendar", "InfoSec Streams", "infosecstreams.com", []calendar.Schedule{{Streamer: streamer("alice"), Segments: last}})
+	fs := afero.NewMemMapFs()
+	if err := b.Commit(fs); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	p := fakeProvider{
+		schedules: map[string][]calendar.Segment{"bob": {{ID: "seg-b1", Start: start}}},
+		errs:      map[string]error{"alice": errors.New("boom"), "mallory": errors.New("boom")},
+	}
+	found, failed := calendar.Fetch(context.Background(), p, []streamers.Streamer{streamer("alice"), streamer("bob"), streamer("mallory")})
+	schedules, err := calendar.Keep(fs, "calendar", found, failed)
+	if err != nil {
+		t.Fatalf("Keep failed: %v", err)
+	}
+	if len(schedules) != 2 || schedules[0].Streamer.Name != "bob" || schedules[1].Streamer.Name != "alice" {
+		t.Fatalf("Got: %+v, Wanted bob's new schedule and alice's last one, mallory never had a calendar", schedules)
+	}
+	if !reflect.DeepEqual(schedules[1].Segments, last) {
+		t.Errorf("Got: %+v, Wanted: %+v", schedules[1].Segments, last)
+	}
+}
+
+func TestTwitchSchedule(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Client-Id") != "client" || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/users" && r.URL.Query().Get("login") == "alice":
+			w.Write([]byte(`{"data":[{"id":"123"}]}`))
+		case r.URL.Path == "/users" && r.URL.Query().Get("login") == "bob":
+			w.Write([]byte(`{"data":[{"id":"456"}]}`))
+		case r.URL.Path == "/users":
+			w.Write([]byte(`{"data":[]}`))
+		case r.URL.Path == "/schedule" && r.URL.Query().Get("broadcaster_id") == "123":
+			w.Write([]byte(`{"data":{"segments":[
+				{"id":"s1","start_time":"2026-10-20T18:00:00Z","end_time":"2026-10-20T20:00:00Z","title":"Reversing","canceled_until":null,"category":{"name":"Software and Game Development"}},
+				{"id":"s2","start_time":"2026-10-21T18:00:00Z","end_time":null,"title":"Off","canceled_until":"2026-10-21T20:00:00Z","category":null}
+			]}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	twitch := calendar.Twitch{ClientID: "client", Token: "token", BaseURL: server.URL}
+
+	segments, err := twitch.Schedule(context.Background(), streamer("alice"))
+	if err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+	want := []calendar.Segment{
+		{ID: "s1", Title: "Reversing", Category: "Software and Game Development", Start: time.Date(2026, 10, 20, 18, 0, 0, 0, time.UTC), End: time.Date(2026, 10, 20, 20, 0, 0, 0, time.UTC)},
+		{ID: "s2", Title: "Off", Start: time.Date(2026, 10, 21, 18, 0, 0, 0, time.UTC), Canceled: true},
+	}
+	if !reflect.DeepEqual(segments, want) {
+		t.Fatalf("Got: %+v, Wanted: %+v", segments, want)
+	}
+
+	if segments, err := twitch.Schedule(context.Background(), streamer("bob")); err != nil || len(segments) != 0 {
+		t.Fatalf("bob has no schedule, Got: %v, %v", segments, err)
+	}
+	if segments, err := twitch.Schedule(context.Background(), streamer("nobody")); err != nil || len(segments) != 0 {
+		t.Fatalf("unknown users have no schedule, Got: %v, %v", segments, err)
+	}
+	if _, err := (calendar.Twitch{BaseURL: server.URL}).Schedule(context.Background(), streamer("alice")); err == nil {
+		t.Fatalf("unauthorized requests should fail")
+	}
+}
@@ -0,0 +1,119 @@
+package calendar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/infosecstreams/secinfo/streamers"
+)
+
+// TwitchAPI is the default base url of the Twitch Helix API.
+const TwitchAPI = "https://api.twitch.tv/helix"
+
+// Twitch is a ScheduleProvider backed by the Twitch Helix API. It needs an app access token.
+// See https://dev.twitch.tv/docs/api/reference/#get-channel-stream-schedule.
+type Twitch struct {
+	ClientID string       // The Twitch application's client ID
+	Token    string       // An app access token for the application
+	BaseURL  string       // The Helix API url, TwitchAPI if empty
+	Client   *http.Client // The client to send requests with, http.DefaultClient if nil
+}
+
+// Schedule returns the streamer's upcoming Twitch schedule segments.
+// A streamer without a Twitch account, whose Twitch user doesn't exist, or without a published schedule, has no segments.
+func (t Twitch) Schedule(ctx context.Context, s streamers.Streamer) ([]Segment, error) {
+	a, ok := s.Account(streamers.Twitch)
+	if !ok {
+		return nil, nil
+	}
+
+	var users struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if _, err := t.get(ctx, "/users", url.Values{"login": {a.Handle}}, &users); err != nil {
+		return nil, err
+	}
+	// A renamed or deleted account has nothing scheduled, it shouldn't keep the other calendars from updating
+	if len(users.Data) == 0 {
+		return nil, nil
+	}
+
+	var schedule struct {
+		Data struct {
+			Segments []struct {
+				ID            string     `json:"id"`
+				StartTime     time.Time  `json:"start_time"`
+				EndTime       *time.Time `json:"end_time"`
+				Title         string     `json:"title"`
+				CanceledUntil *string    `json:"canceled_until"`
+				Category      *struct {
+					Name string `json:"name"`
+				} `json:"category"`
+			} `json:"segments"`
+		} `json:"data"`
+	}
+	found, err := t.get(ctx, "/schedule", url.Values{"broadcaster_id": {users.Data[0].ID}, "first": {"25"}}, &schedule)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	var segments []Segment
+	for _, seg := range schedule.Data.Segments {
+		segment := Segment{
+			ID:       seg.ID,
+			Title:    seg.Title,
+			Start:    seg.StartTime,
+			Canceled: seg.CanceledUntil != nil,
+		}
+		if seg.EndTime != nil {
+			segment.End = *seg.EndTime
+		}
+		if seg.Category != nil {
+			segment.Category = seg.Category.Name
+		}
+		segments = append(segments, segment)
+	}
+	return segments, nil
+}
+
+// get decodes the json response of a Helix endpoint into v. It reports false for a 404,
+// which Helix returns for a channel without a schedule.
+func (t Twitch) get(ctx context.Context, endpoint string, query url.Values, v any) (bool, error) {
+	base := t.BaseURL
+	if base == "" {
+		base = TwitchAPI
+	}
+	request, err := http.NewRequestWithContext(ctx, "GET", base+endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Client-Id", t.ClientID)
+	request.Header.Set("Authorization", "Bearer "+t.Token)
+
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	r, err := client.Do(request)
+	if err != nil {
+		return false, err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if r.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("twitch %s: %s", endpoint, r.Status)
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return false, fmt.Errorf("decoding twitch %s: %w", endpoint, err)
+	}
+	return true, nil
+}
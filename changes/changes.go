@@ -0,0 +1,117 @@
+/* Package changes compares the streamer lists of two runs and reports what changed between them. */
+package changes
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/infosecstreams/secinfo/streamers"
+)
+
+// Kind is the kind of change an Event reports.
+type Kind string
+
+// The kinds of change between two runs.
+const (
+	Added    Kind = "added"    // New to the list
+	Removed  Kind = "removed"  // Dropped from both csv files
+	Promoted Kind = "promoted" // Moved from the inactive list to the active list
+	Demoted  Kind = "demoted"  // Moved from the active list to the inactive list
+	WentLive Kind = "live"     // Went live since the last run
+)
+
+// Kinds lists every Kind.
+var Kinds = []Kind{Added, Removed, Promoted, Demoted, WentLive}
+
+// Run is the state of the lists at the end of a run.
+type Run struct {
+	Active   []streamers.Streamer // The active list
+	Inactive []streamers.Streamer // The inactive list
+}
+
+// Event is a single change to a streamer between two runs.
+type Event struct {
+	Kind     Kind               // What changed
+	Streamer streamers.Streamer // The streamer as of the current run, or the previous run if Removed
+	Previous streamers.Streamer // The streamer as of the previous run, zero if Added
+}
+
+// Diff returns the changes from prev to cur, grouped by Kind in the order of Kinds and then by name.
+// Streamers are matched by name, case-insensitively. A prev without any streamers is treated as
+// unknown, e.g. the very first run, and reports no changes rather than adding everyone.
+func Diff(prev, cur Run) []Event {
+	if len(prev.Active)+len(prev.Inactive) == 0 {
+		return nil
+	}
+
+	prevActive, prevInactive := byName(prev.Active), byName(prev.Inactive)
+	curActive, curInactive := byName(cur.Active), byName(cur.Inactive)
+
+	var events []Event
+	for key, s := range curActive {
+		if p, ok := prevActive[key]; ok {
+			if s.Online && !p.Online {
+				events = append(events, Event{Kind: WentLive, Streamer: s, Previous: p})
+			}
+		} else if p, ok := prevInactive[key]; ok {
+			events = append(events, Event{Kind: Promoted, Streamer: s, Previous: p})
+		} else {
+			events = append(events, Event{Kind: Added, Streamer: s})
+		}
+	}
+	for key, s := range curInactive {
+		if p, ok := prevActive[key]; ok {
+			events = append(events, Event{Kind: Demoted, Streamer: s, Previous: p})
+		} else if _, ok := prevInactive[key]; !ok {
+			events = append(events, Event{Kind: Added, Streamer: s})
+		}
+	}
+	for _, prevList := range []map[string]streamers.Streamer{prevActive, prevInactive} {
+		for key, p := range prevList {
+			_, active := curActive[key]
+			_, inactive := curInactive[key]
+			if !active && !inactive {
+				events = append(events, Event{Kind: Removed, Streamer: p, Previous: p})
+			}
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Kind != events[j].Kind {
+			return kindRank(events[i].Kind) < kindRank(events[j].Kind)
+		}
+		return strings.ToLower(events[i].Streamer.Name) < strings.ToLower(events[j].Streamer.Name)
+	})
+	return events
+}
+
+// Filter returns the events of the given kinds.
+func Filter(events []Event, kinds ...Kind) []Event {
+	var filtered []Event
+	for _, e := range events {
+		for _, k := range kinds {
+			if e.Kind == k {
+				filtered = append(filtered, e)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+func byName(list []streamers.Streamer) map[string]streamers.Streamer {
+	m := make(map[string]streamers.Streamer, len(list))
+	for _, s := range list {
+		m[strings.ToLower(s.Name)] = s
+	}
+	return m
+}
+
+func kindRank(k Kind) int {
+	for i, known := range Kinds {
+		if known == k {
+			return i
+		}
+	}
+	return len(Kinds)
+}
@@ -0,0 +1,78 @@
+package changes_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/infosecstreams/secinfo/changes"
+	"github.com/infosecstreams/secinfo/streamers"
+)
+
+func names(events []changes.Event) []string {
+	var got []string
+	for _, e := range events {
+		got = append(got, fmt.Sprintf("%s %s", e.Kind, e.Streamer.Name))
+	}
+	return got
+}
+
+func TestDiff(t *testing.T) {
+	prev := changes.Run{
+		Active: []streamers.Streamer{
+			{Name: "alice"},
+			{Name: "bob", Online: true},
+			{Name: "carol"},
+			{Name: "dave"},
+		},
+		Inactive: []streamers.Streamer{
+			{Name: "erin"},
+			{Name: "frank"},
+		},
+	}
+	cur := changes.Run{
+		Active: []streamers.Streamer{
+			{Name: "Alice", Online: true}, // went live, matched case-insensitively
+			{Name: "bob", Online: true},   // still live
+			{Name: "erin"},                // promoted
+			{Name: "zed"},                 // added
+		},
+		Inactive: []streamers.Streamer{
+			{Name: "carol"}, // demoted
+			{Name: "frank"},
+			{Name: "yan"}, // added straight to inactive
+		},
+	}
+
+	got := names(changes.Diff(prev, cur))
+	want := []string{
+		"added yan",
+		"added zed",
+		"removed dave",
+		"promoted erin",
+		"demoted carol",
+		"live Alice",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got: %q, Wanted: %q", got, want)
+	}
+}
+
+func TestDiffFirstRun(t *testing.T) {
+	cur := changes.Run{Active: []streamers.Streamer{{Name: "alice", Online: true}}}
+	if events := changes.Diff(changes.Run{}, cur); len(events) != 0 {
+		t.Fatalf("first run should report nothing, Got: %q", names(events))
+	}
+}
+
+func TestFilter(t *testing.T) {
+	events := []changes.Event{
+		{Kind: changes.Added, Streamer: streamers.Streamer{Name: "a"}},
+		{Kind: changes.WentLive, Streamer: streamers.Streamer{Name: "b"}},
+		{Kind: changes.Demoted, Streamer: streamers.Streamer{Name: "c"}},
+	}
+	got := names(changes.Filter(events, changes.WentLive, changes.Added))
+	if want := []string{"added a", "live b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got: %q, Wanted: %q", got, want)
+	}
+}
@@ -0,0 +1,130 @@
+/* Package chart draws bar charts as standalone SVG images, so pages can show trends without scripts. */
+package chart
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+
+	"github.com/infosecstreams/secinfo/history"
+)
+
+// Default size of a chart in pixels.
+const (
+	DefaultWidth  = 600
+	DefaultHeight = 200
+)
+
+// Space around the plot for the title, the axis and the labels.
+const (
+	marginTop    = 30
+	marginRight  = 10
+	marginBottom = 24
+	marginLeft   = 44
+	maxLabels    = 8 // Labels under the bars, every few bars get one if there are more
+)
+
+// Bar is a single bar of a chart.
+type Bar struct {
+	Label string  // What the bar is, e.g. a date
+	Value float64 // The bar's height in the chart's unit
+}
+
+// Chart is a bar chart.
+type Chart struct {
+	Title  string // Drawn above the bars
+	Unit   string // Appended to values, e.g. "h"
+	Bars   []Bar  // The bars from left to right
+	Width  int    // DefaultWidth if zero
+	Height int    // DefaultHeight if zero
+}
+
+// Activity returns a chart of a streamer's hours in the stats window on each day of their history.
+func Activity(name string, points []history.Point, windowDays int) Chart {
+	c := Chart{Title: fmt.Sprintf("%s: hours streamed in the last %d days", name, windowDays), Unit: "h"}
+	for _, p := range points {
+		c.Bars = append(c.Bars, Bar{Label: p.Time.Format("Jan 2"), Value: float64(p.Hours)})
+	}
+	return c
+}
+
+// HoursPerWeek returns a chart of the hours the whole list streamed each week.
+func HoursPerWeek(totals []history.Total) Chart {
+	c := Chart{Title: "Total hours streamed per week", Unit: "h"}
+	for _, t := range totals {
+		c.Bars = append(c.Bars, Bar{Label: t.Week.Format("Jan 2"), Value: float64(t.Hours)})
+	}
+	return c
+}
+
+// SVG draws the chart. The output only depends on the chart, so it can be committed and compared.
+func (c Chart) SVG() []byte {
+	width, height := c.Width, c.Height
+	if width == 0 {
+		width = DefaultWidth
+	}
+	if height == 0 {
+		height = DefaultHeight
+	}
+	plotWidth, plotHeight := float64(width-marginLeft-marginRight), float64(height-marginTop-marginBottom)
+	bottom := float64(height - marginBottom)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" role="img" aria-label="%s" font-family="sans-serif" font-size="11">`+"\n", width, height, width, height, html.EscapeString(c.Title))
+	fmt.Fprintf(&b, `<title>%s</title>`+"\n", html.EscapeString(c.Title))
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/>`+"\n", width, height)
+	fmt.Fprintf(&b, `<text x="%d" y="18" font-size="13" font-weight="bold" fill="#222">%s</text>`+"\n", marginLeft, html.EscapeString(c.Title))
+
+	if len(c.Bars) == 0 {
+		fmt.Fprintf(&b, `<text x="%s" y="%s" text-anchor="middle" fill="#777">No data yet</text>`+"\n", num(marginLeft+plotWidth/2), num(marginTop+plotHeight/2))
+		b.WriteString("</svg>\n")
+		return b.Bytes()
+	}
+
+	// The axis goes up to a round number above the highest bar, with a grid line at its middle
+	top := 0.0
+	for _, bar := range c.Bars {
+		top = math.Max(top, bar.Value)
+	}
+	top = niceCeil(top)
+	for _, v := range []float64{0, top / 2, top} {
+		y := bottom - v/top*plotHeight
+		fmt.Fprintf(&b, `<line x1="%d" y1="%s" x2="%d" y2="%s" stroke="#ddd"/>`+"\n", marginLeft, num(y), width-marginRight, num(y))
+		fmt.Fprintf(&b, `<text x="%d" y="%s" text-anchor="end" fill="#555">%s%s</text>`+"\n", marginLeft-4, num(y+4), num(v), html.EscapeString(c.Unit))
+	}
+
+	slot := plotWidth / float64(len(c.Bars))
+	every := (len(c.Bars) + maxLabels - 1) / maxLabels
+	for i, bar := range c.Bars {
+		x := float64(marginLeft) + float64(i)*slot
+		h := math.Max(bar.Value, 0) / top * plotHeight
+		fmt.Fprintf(&b, `<rect x="%s" y="%s" width="%s" height="%s" fill="#9146ff"><title>%s: %s%s</title></rect>`+"\n",
+			num(x+slot*0.1), num(bottom-h), num(slot*0.8), num(h), html.EscapeString(bar.Label), num(bar.Value), html.EscapeString(c.Unit))
+		if i%every == 0 {
+			fmt.Fprintf(&b, `<text x="%s" y="%d" text-anchor="middle" fill="#555">%s</text>`+"\n", num(x+slot/2), height-8, html.EscapeString(bar.Label))
+		}
+	}
+	b.WriteString("</svg>\n")
+	return b.Bytes()
+}
+
+// niceCeil rounds v up to 1, 2 or 5 times a power of ten, and to 1 if v isn't positive.
+func niceCeil(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, step := range []float64{1, 2, 5, 10} {
+		if v <= step*magnitude {
+			return step * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+// num formats a coordinate or value with at most one decimal, e.g. "12" or "12.5".
+func num(v float64) string {
+	return strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64)
+}
@@ -0,0 +1,66 @@
+package chart_test
+
+import (
+	"encoding/xml"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/infosecstreams/secinfo/changes"
+	"github.com/infosecstreams/secinfo/chart"
+	"github.com/infosecstreams/secinfo/history"
+	"github.com/infosecstreams/secinfo/streamers"
+)
+
+var update = flag.Bool("update", false, "update the golden .svg files in testdata")
+
+func TestSVG(t *testing.T) {
+	var h history.History
+	start := time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC) // A Tuesday
+	for day := range 21 {
+		alice := streamers.Streamer{Name: "<alice>", ThirtyDayStats: float32(20 + day)}
+		bob := streamers.Streamer{Name: "bob", ThirtyDayStats: 30}
+		h.Record(changes.Run{Active: []streamers.Streamer{alice, bob}}, start.AddDate(0, 0, day))
+	}
+
+	for file, c := range map[string]chart.Chart{
+		"activity.svg":       chart.Activity("<alice>", h.Points("<alice>"), 30),
+		"hours-per-week.svg": chart.HoursPerWeek(h.WeeklyHours(30)),
+		"empty.svg":          chart.Activity("carol", nil, 30),
+	} {
+		data := c.SVG()
+		if err := xml.Unmarshal(data, new(struct{})); err != nil {
+			t.Errorf("%s isn't well-formed: %v\n%s", file, err, data)
+		}
+
+		golden := filepath.Join("testdata", file)
+		if *update {
+			if err := os.WriteFile(golden, data, 0644); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+		if string(data) != string(want) {
+			t.Errorf("%s doesn't match %s, run go test -update if the change is intended:\n%s", file, golden, data)
+		}
+	}
+}
+
+func TestWeeklyHours(t *testing.T) {
+	var h history.History
+	monday := time.Date(2026, 9, 7, 0, 0, 0, 0, time.UTC)
+	h.Record(changes.Run{Active: []streamers.Streamer{{Name: "alice", ThirtyDayStats: 10}}}, monday.Add(-time.Hour))
+	h.Record(changes.Run{Active: []streamers.Streamer{{Name: "alice", ThirtyDayStats: 30}, {Name: "bob", ThirtyDayStats: 60}}}, monday.Add(time.Hour))
+	h.Record(changes.Run{Active: []streamers.Streamer{{Name: "alice", ThirtyDayStats: 60}, {Name: "bob", ThirtyDayStats: 60}}}, monday.AddDate(0, 0, 3))
+
+	c := chart.HoursPerWeek(h.WeeklyHours(30))
+	want := []chart.Bar{{Label: "Aug 31", Value: float64(float32(10) * 7 / 30)}, {Label: "Sep 7", Value: 28}}
+	if len(c.Bars) != len(want) || c.Bars[0] != want[0] || c.Bars[1] != want[1] {
+		t.Errorf("Got: %+v, Wanted: %+v", c.Bars, want)
+	}
+}
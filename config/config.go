@@ -0,0 +1,539 @@
+/*
+Package config holds the settings of a run: where the lists, templates and outputs live, how the stats are fetched,
+and when a streamer counts as active.
+
+Settings come from a JSON file, secinfo.json by default, on top of Default. Environment variables override the
+file, see OverrideKeys, so a scheduled workflow can change a setting without editing it.
+
+A run processes a single list configured at the top level of the file, or several named lists under "lists".
+Every list has its own csv files, templates, outputs and threshold, and shares the stats settings.
+*/
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/infosecstreams/secinfo/calendar"
+	"github.com/infosecstreams/secinfo/export"
+	"github.com/infosecstreams/secinfo/replay"
+	"github.com/infosecstreams/secinfo/streamers"
+	"github.com/spf13/afero"
+)
+
+// DefaultPath is the config file read when SECINFO_CONFIG doesn't name one.
+const DefaultPath = "secinfo.json"
+
+// Paths are where a run reads its lists and templates and writes its outputs, relative to the working directory.
+type Paths struct {
+	Streamers        string `json:"streamers"`         // The active list's csv
+	Inactive         string `json:"inactive"`          // The inactive list's csv
+	IndexTemplate    string `json:"index_template"`    // The template of the active page
+	InactiveTemplate string `json:"inactive_template"` // The template of the inactive page
+	LinksTemplate    string `json:"links_template"`    // Partial templates shared by both pages
+	ProfileTemplate  string `json:"profile_template"`  // The template of a streamer's profile page
+	Index            string `json:"index"`             // The rendered active page
+	InactivePage     string `json:"inactive_page"`     // The rendered inactive page
+	Languages        string `json:"languages"`         // The directory of the per-language pages, not rendered if empty
+	Profiles         string `json:"profiles"`          // The directory of the streamers' profile pages, not rendered or linked if empty, the default
+	ActiveJSON       string `json:"active_json"`       // The active list's state between runs
+	InactiveJSON     string `json:"inactive_json"`     // The inactive list's state between runs
+	History          string `json:"history"`           // Every streamer's hours and rank over time
+	Feed             string `json:"feed"`              // The Atom feed
+	API              string `json:"api"`               // The directory of the JSON API
+	Export           string `json:"export"`            // The directory of the exports
+	Calendar         string `json:"calendar"`          // The directory of the calendars
+	Charts           string `json:"charts"`            // The directory of the SVG charts, not drawn if empty
+	NotifyState      string `json:"notify_state"`      // What's been sent to each notification channel
+	HTMLDir          string `json:"html_dir"`          // The directory of the HTML site, not rendered if empty
+	PRBody           string `json:"pr_body"`           // The pull request description, not written if empty
+}
+
+// fields returns every path with the name of its setting.
+func (p *Paths) fields() []struct {
+	setting string
+	value   *string
+} {
+	return []struct {
+		setting string
+		value   *string
+	}{
+		{"streamers", &p.Streamers},
+		{"inactive", &p.Inactive},
+		{"index_template", &p.IndexTemplate},
+		{"inactive_template", &p.InactiveTemplate},
+		{"links_template", &p.LinksTemplate},
+		{"profile_template", &p.ProfileTemplate},
+		{"index", &p.Index},
+		{"inactive_page", &p.InactivePage},
+		{"languages", &p.Languages},
+		{"profiles", &p.Profiles},
+		{"active_json", &p.ActiveJSON},
+		{"inactive_json", &p.InactiveJSON},
+		{"history", &p.History},
+		{"feed", &p.Feed},
+		{"api", &p.API},
+		{"export", &p.Export},
+		{"calendar", &p.Calendar},
+		{"charts", &p.Charts},
+		{"notify_state", &p.NotifyState},
+		{"html_dir", &p.HTMLDir},
+		{"pr_body", &p.PRBody},
+	}
+}
+
+// fill sets the empty paths to the default ones inside dir.
+func (p *Paths) fill(dir string) {
+	defaults := Default().Paths
+	fields, defaultFields := p.fields(), defaults.fields()
+	for i, f := range fields {
+		if *f.value == "" && *defaultFields[i].value != "" {
+			*f.value = path.Join(dir, *defaultFields[i].value)
+		}
+	}
+}
+
+// Sorting picks the sort strategy of each output by name, see streamers.Orders.
+// The json state and the API are always sorted by hours, since ranks come from that order, and the csv files by name.
+type Sorting struct {
+	Index    string `json:"index"`    // index.md and the per-language pages
+	Inactive string `json:"inactive"` // inactive.md and the HTML site's inactive page
+	HTML     string `json:"html"`     // The HTML site's active page
+	Exports  string `json:"exports"`  // The exports
+}
+
+// fields returns every output's strategy with the name of its setting.
+func (s *Sorting) fields() []struct {
+	setting string
+	value   *string
+} {
+	return []struct {
+		setting string
+		value   *string
+	}{{"index", &s.Index}, {"inactive", &s.Inactive}, {"html", &s.HTML}, {"exports", &s.Exports}}
+}
+
+// List is the settings of one streamer list.
+type List struct {
+	Name     string   `json:"name"`      // Names the list in logs and reports, required when there are several
+	Title    string   `json:"title"`     // The title of the list's feed
+	SiteURL  string   `json:"site_url"`  // The url of the site that publishes the list
+	Paths    Paths    `json:"paths"`     // Defaults to the top level's default paths inside a directory named after the list
+	MinHours float32  `json:"min_hours"` // A streamer is active with more hours than this
+	Exports  []string `json:"exports"`   // Export formats by name, every registered format if empty
+	Sort     Sorting  `json:"sort"`      // How each output is sorted, outputs left out keep the default
+}
+
+// Order returns the sort strategy named by name, ByHours if it isn't known.
+func Order(name string) streamers.Order {
+	if order, ok := streamers.Orders[name]; ok {
+		return order
+	}
+	return streamers.ByHours
+}
+
+// Active reports whether a streamer with the given hours belongs on the active list.
+func (l List) Active(hours float32) bool {
+	return hours > l.MinHours
+}
+
+// Config is the settings of a run.
+type Config struct {
+	List                             // The list processed when Lists is empty
+	Lists          []List            `json:"lists"`           // Lists processed in one run instead of the top level one
+	UserAgent      string            `json:"user_agent"`      // The User-Agent sent to stats providers
+	WindowDays     int               `json:"window_days"`     // Days of activity counted, one of streamers.SullyGnomeWindows
+	Providers      []string          `json:"providers"`       // Stats providers by name, see ProviderNames
+	SullyGnomeURL  string            `json:"sullygnome_url"`  // SullyGnome's base url
+	DeriveTags     bool              `json:"derive_tags"`     // Whether to tag streamers by the category they stream in most
+	CategoryTags   map[string]string `json:"category_tags"`   // Tags by category, streamers.DefaultCategoryTags if nil
+	Cassette       string            `json:"cassette"`        // A directory of recorded HTTP responses to replay instead of using the network
+	Record         bool              `json:"record"`          // Whether to record the responses to Cassette instead of replaying them
+	Test           bool              `json:"test"`            // Whether to reuse the last run's json instead of fetching stats and writing data
+	TwitchID       string            `json:"-"`               // The Twitch API client id schedules are fetched with, from the environment only
+	TwitchToken    string            `json:"-"`               // The Twitch API token schedules are fetched with, from the environment only
+	Timeout        Duration          `json:"timeout"`         // How long a whole run may take before it stops without writing anything, unlimited if zero
+	RequestTimeout Duration          `json:"request_timeout"` // How long a single request may take before it fails, unlimited if zero
+	MaxFailures    float64           `json:"max_failures"`    // The percentage of a list's lookups that may fail before FailurePolicy applies
+	FailurePolicy  string            `json:"failure_policy"`  // What a run does when more lookups fail, one of FailurePolicies
+}
+
+// Duration is a time.Duration written as a string in the config file, e.g. "30s" or "10m".
+type Duration time.Duration
+
+// MarshalJSON writes the duration as a string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON reads a duration string.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("durations are strings like \"30s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	*d = Duration(v)
+	return err
+}
+
+// Default returns the settings used when nothing is configured.
+func Default() Config {
+	return Config{
+		List: List{
+			Title:   "InfoSec Streams",
+			SiteURL: "https://infosecstreams.com",
+			Paths: Paths{
+				Streamers:        "streamers.csv",
+				Inactive:         "inactive_streamers.csv",
+				IndexTemplate:    "templates/index.tmpl.md",
+				InactiveTemplate: "templates/inactive.tmpl.md",
+				LinksTemplate:    "templates/links.tmpl",
+				ProfileTemplate:  "templates/streamer.tmpl.md",
+				Index:            "index.md",
+				InactivePage:     "inactive.md",
+				Languages:        "lang",
+				ActiveJSON:       "active.json",
+				InactiveJSON:     "inactive.json",
+				Feed:             "atom.xml",
+				API:              "api",
+				Export:           "export",
+				Calendar:         "calendar",
+				Charts:           "charts",
+				History:          "history.json",
+				NotifyState:      "notify_state.json",
+			},
+			Sort: Sorting{Index: "hours", Inactive: "name", HTML: "hours", Exports: "hours"},
+		},
+		UserAgent:      streamers.DefaultUserAgent,
+		WindowDays:     streamers.WindowDays,
+		Providers:      []string{"sullygnome"},
+		SullyGnomeURL:  streamers.SullyGnomeURL,
+		Timeout:        Duration(30 * time.Minute),
+		RequestTimeout: Duration(30 * time.Second),
+		MaxFailures:    50,
+		FailurePolicy:  Abort,
+	}
+}
+
+// Load reads the config file at path on top of Default, then applies the environment overrides.
+// An empty path reads DefaultPath if it exists. Unknown fields in the file are an error, so typos don't go unnoticed.
+func Load(fileSystem afero.Fs, path string) (Config, error) {
+	c := Default()
+	required := path != ""
+	if !required {
+		path = DefaultPath
+	}
+
+	data, err := afero.ReadFile(fileSystem, path)
+	switch {
+	case err == nil:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&c); err != nil {
+			return c, fmt.Errorf("reading %s: %w", path, err)
+		}
+		for i := range c.Lists {
+			l := &c.Lists[i]
+			l.Paths.fill(l.Name)
+			defaults := Default().Sort
+			fields, defaultFields := l.Sort.fields(), defaults.fields()
+			for i, f := range fields {
+				if *f.value == "" {
+					*f.value = *defaultFields[i].value
+				}
+			}
+			if l.Title == "" {
+				l.Title = c.Title
+			}
+			if l.SiteURL == "" {
+				l.SiteURL = c.SiteURL
+			}
+		}
+	case !os.IsNotExist(err) || required:
+		return c, err
+	}
+
+	return c, c.override(os.Getenv)
+}
+
+// AllLists returns the lists a run processes: Lists, or the top level list if there are none.
+func (c Config) AllLists() []List {
+	if len(c.Lists) == 0 {
+		return []List{c.List}
+	}
+	return c.Lists
+}
+
+// overrides are the environment variables that override settings, in the order they're applied.
+// Paths, thresholds and exports only override the top level list.
+var overrides = []struct {
+	key string
+	set func(c *Config, value string) error
+}{
+	{"SECINFO_STREAMERS_CSV", func(c *Config, v string) error { c.Paths.Streamers = v; return nil }},
+	{"SECINFO_INACTIVE_CSV", func(c *Config, v string) error { c.Paths.Inactive = v; return nil }},
+	{"SECINFO_HTML_DIR", func(c *Config, v string) error { c.Paths.HTMLDir = v; return nil }},
+	{"SECINFO_PROFILES", func(c *Config, v string) error { c.Paths.Profiles = v; return nil }},
+	{"SECINFO_PR_BODY", func(c *Config, v string) error { c.Paths.PRBody = v; return nil }},
+	{"SECINFO_USER_AGENT", func(c *Config, v string) error { c.UserAgent = v; return nil }},
+	{"SECINFO_WINDOW_DAYS", func(c *Config, v string) (err error) { c.WindowDays, err = strconv.Atoi(v); return err }},
+	{"SECINFO_MIN_HOURS", func(c *Config, v string) error {
+		hours, err := strconv.ParseFloat(v, 32)
+		c.MinHours = float32(hours)
+		return err
+	}},
+	{"SECINFO_PROVIDERS", func(c *Config, v string) error { c.Providers = splitList(v); return nil }},
+	{"SECINFO_SULLYGNOME_URL", func(c *Config, v string) error { c.SullyGnomeURL = v; return nil }},
+	{"SECINFO_EXPORTS", func(c *Config, v string) error { c.Exports = splitList(v); return nil }},
+	{"SECINFO_DERIVE_TAGS", func(c *Config, v string) (err error) { c.DeriveTags, err = strconv.ParseBool(v); return err }},
+	{"SECINFO_CASSETTE", func(c *Config, v string) error { c.Cassette = v; return nil }},
+	{"SECINFO_RECORD", func(c *Config, v string) (err error) { c.Record, err = strconv.ParseBool(v); return err }},
+	{"SECINFO_TEST", func(c *Config, _ string) error { c.Test = true; return nil }},
+	{"TWITCH_CLIENT_ID", func(c *Config, v string) error { c.TwitchID = v; return nil }},
+	{"TWITCH_TOKEN", func(c *Config, v string) error { c.TwitchToken = v; return nil }},
+	{"SECINFO_MAX_FAILURES", func(c *Config, v string) (err error) { c.MaxFailures, err = strconv.ParseFloat(v, 64); return err }},
+	{"SECINFO_FAILURE_POLICY", func(c *Config, v string) error { c.FailurePolicy = v; return nil }},
+	{"SECINFO_TIMEOUT", func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		c.Timeout = Duration(d)
+		return err
+	}},
+	{"SECINFO_REQUEST_TIMEOUT", func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		c.RequestTimeout = Duration(d)
+		return err
+	}},
+}
+
+// OverrideKeys lists the environment variables that override settings.
+func OverrideKeys() []string {
+	keys := make([]string, len(overrides))
+	for i, o := range overrides {
+		keys[i] = o.key
+	}
+	return keys
+}
+
+func (c *Config) override(getenv func(string) string) error {
+	var errs []error
+	for _, o := range overrides {
+		if value := getenv(o.key); value != "" {
+			if err := o.set(c, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", o.key, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ProviderNames lists the stats providers a config can name. SullyGnome only has Twitch stats, so the hours of
+// streamers on other platforms aren't counted yet.
+var ProviderNames = []string{"sullygnome"}
+
+// What a run does when more than MaxFailures percent of a list's lookups fail, e.g. because SullyGnome is down.
+const (
+	Abort    = "abort"    // Write nothing
+	Previous = "previous" // Give the streamers whose lookup failed their stats from the last run, and write as usual
+)
+
+// FailurePolicies are the valid values of FailurePolicy.
+var FailurePolicies = []string{Abort, Previous}
+
+// TooManyFailures reports whether failed lookups out of lookups are more than MaxFailures percent.
+func (c Config) TooManyFailures(failed, lookups int) bool {
+	return lookups > 0 && float64(failed)*100 > c.MaxFailures*float64(lookups)
+}
+
+// StatsProviders returns the configured stats providers.
+func (c Config) StatsProviders() ([]streamers.StatsProvider, error) {
+	var providers []streamers.StatsProvider
+	for _, name := range c.Providers {
+		switch name {
+		case "sullygnome":
+			providers = append(providers, streamers.SullyGnome{BaseURL: c.SullyGnomeURL, UserAgent: c.UserAgent, WindowDays: c.WindowDays, Client: c.HTTPClient()})
+		default:
+			return nil, fmt.Errorf("unknown stats provider %q, known providers: %s", name, strings.Join(ProviderNames, ", "))
+		}
+	}
+	return providers, nil
+}
+
+// HTTPClient returns the client providers fetch with. It gives up on a request after RequestTimeout,
+// and records to or replays from Cassette if there is one.
+func (c Config) HTTPClient() *http.Client {
+	client := &http.Client{Timeout: time.Duration(c.RequestTimeout)}
+	if c.Cassette != "" {
+		mode := replay.Replay
+		if c.Record {
+			mode = replay.Record
+		}
+		client.Transport = &replay.Transport{Dir: c.Cassette, Mode: mode}
+	}
+	return client
+}
+
+// NotifyClient returns the client notifications are sent with. It gives up on a request after RequestTimeout
+// but never goes through the cassette: webhook urls hold their secret, which would end up in the recordings' names.
+func (c Config) NotifyClient() *http.Client {
+	return &http.Client{Timeout: time.Duration(c.RequestTimeout)}
+}
+
+// ScheduleProvider returns where Twitch schedules are fetched from, nil without Twitch API credentials.
+func (c Config) ScheduleProvider() calendar.ScheduleProvider {
+	if c.TwitchID == "" || c.TwitchToken == "" {
+		return nil
+	}
+	return calendar.Twitch{ClientID: c.TwitchID, Token: c.TwitchToken, Client: c.HTTPClient()}
+}
+
+// Categories returns the tag each category maps to when tags are derived.
+func (c Config) Categories() map[string]streamers.Tag {
+	if c.CategoryTags == nil {
+		return streamers.DefaultCategoryTags
+	}
+	categories := make(map[string]streamers.Tag, len(c.CategoryTags))
+	for category, name := range c.CategoryTags {
+		if t, ok := streamers.ParseTag(name); ok {
+			categories[category] = t
+		}
+	}
+	return categories
+}
+
+// Validate reports every problem with the settings, checking that the lists and templates exist in fileSystem.
+func (c Config) Validate(fileSystem afero.Fs) error {
+	var errs []error
+	if strings.TrimSpace(c.UserAgent) == "" {
+		errs = append(errs, errors.New("user_agent is empty"))
+	}
+	if !slices.Contains(streamers.SullyGnomeWindows, c.WindowDays) {
+		errs = append(errs, fmt.Errorf("window_days is %d, SullyGnome only has %v", c.WindowDays, streamers.SullyGnomeWindows))
+	}
+	if len(c.Providers) == 0 {
+		errs = append(errs, errors.New("providers is empty, nothing would be active"))
+	}
+	if _, err := c.StatsProviders(); err != nil {
+		errs = append(errs, err)
+	}
+	if u, err := url.Parse(c.SullyGnomeURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("sullygnome_url %q isn't an http(s) url", c.SullyGnomeURL))
+	}
+	if c.Timeout < 0 || c.RequestTimeout < 0 {
+		errs = append(errs, fmt.Errorf("timeout %s and request_timeout %s can't be negative", time.Duration(c.Timeout), time.Duration(c.RequestTimeout)))
+	}
+	if c.MaxFailures < 0 || c.MaxFailures > 100 {
+		errs = append(errs, fmt.Errorf("max_failures is %v, it's a percentage from 0 to 100", c.MaxFailures))
+	}
+	if !slices.Contains(FailurePolicies, c.FailurePolicy) {
+		errs = append(errs, fmt.Errorf("unknown failure_policy %q, known policies: %s", c.FailurePolicy, strings.Join(FailurePolicies, ", ")))
+	}
+	if c.Record && c.Cassette == "" {
+		errs = append(errs, errors.New("record is set without a cassette to record to"))
+	}
+	if c.Cassette != "" && !c.Record {
+		if info, err := fileSystem.Stat(c.Cassette); err != nil || !info.IsDir() {
+			errs = append(errs, fmt.Errorf("cassette %q isn't a directory to replay", c.Cassette))
+		}
+	}
+	for category, name := range c.CategoryTags {
+		if _, ok := streamers.ParseTag(name); !ok {
+			errs = append(errs, fmt.Errorf("category_tags: %q maps to unknown tag %q, known tags: %v", category, name, streamers.Tags))
+		}
+	}
+
+	if len(c.Lists) == 0 {
+		return errors.Join(append(errs, c.List.validate(fileSystem, "", c.Test))...)
+	}
+	names := map[string]bool{}
+	writers := map[string]string{}
+	for i, l := range c.Lists {
+		prefix := fmt.Sprintf("lists[%d].", i)
+		if l.Name == "" {
+			errs = append(errs, fmt.Errorf("%sname is empty", prefix))
+		} else if names[l.Name] {
+			errs = append(errs, fmt.Errorf("%sname %q is used by another list", prefix, l.Name))
+		}
+		names[l.Name] = true
+		errs = append(errs, l.validate(fileSystem, prefix, c.Test))
+
+		// Two lists writing the same file would overwrite each other's output
+		for _, f := range l.Paths.fields() {
+			if *f.value == "" || strings.HasSuffix(f.setting, "template") {
+				continue
+			}
+			if other, ok := writers[*f.value]; ok {
+				errs = append(errs, fmt.Errorf("%spaths.%s %q is also %s", prefix, f.setting, *f.value, other))
+			}
+			writers[*f.value] = prefix + "paths." + f.setting
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validate reports the problems with a list, naming its settings with prefix.
+// Test runs reuse the last run's json, so they don't need the csv to exist.
+func (l List) validate(fileSystem afero.Fs, prefix string, test bool) error {
+	var errs []error
+	for _, f := range l.Paths.fields() {
+		switch f.setting {
+		case "links_template", "languages", "profiles", "charts", "html_dir", "pr_body":
+			continue // optional
+		}
+		if *f.value == "" {
+			errs = append(errs, fmt.Errorf("%spaths.%s is empty", prefix, f.setting))
+		}
+	}
+	templates := []string{l.Paths.IndexTemplate, l.Paths.InactiveTemplate, l.Paths.LinksTemplate}
+	if !test {
+		templates = append(templates, l.Paths.Streamers)
+	}
+	if l.Paths.Profiles != "" {
+		templates = append(templates, l.Paths.ProfileTemplate)
+	}
+	for _, path := range templates {
+		if path == "" {
+			continue
+		}
+		if _, err := fileSystem.Stat(path); err != nil {
+			errs = append(errs, fmt.Errorf("%s%w", prefix, err))
+		}
+	}
+	for _, f := range l.Sort.fields() {
+		if _, ok := streamers.Orders[*f.value]; !ok {
+			errs = append(errs, fmt.Errorf("%ssort.%s %q isn't a sort order, known orders: %s", prefix, f.setting, *f.value, strings.Join(streamers.OrderNames, ", ")))
+		}
+	}
+	if l.MinHours < 0 {
+		errs = append(errs, fmt.Errorf("%smin_hours is %v, it can't be negative", prefix, l.MinHours))
+	}
+	if u, err := url.Parse(l.SiteURL); err != nil || u.Host == "" {
+		errs = append(errs, fmt.Errorf("%ssite_url %q isn't a url", prefix, l.SiteURL))
+	}
+	for _, name := range l.Exports {
+		if _, ok := export.Get(name); !ok {
+			errs = append(errs, fmt.Errorf("%sunknown export %q, known exports: %s", prefix, name, strings.Join(export.Names(), ", ")))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,246 @@
+package config_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/infosecstreams/secinfo/calendar"
+	"github.com/infosecstreams/secinfo/config"
+	"github.com/infosecstreams/secinfo/replay"
+	"github.com/infosecstreams/secinfo/streamers"
+	"github.com/spf13/afero"
+)
+
+func TestLoadDefaults(t *testing.T) {
+	c, err := config.Load(afero.NewMemMapFs(), "")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !reflect.DeepEqual(c, config.Default()) {
+		t.Errorf("Got: %+v, Wanted: %+v", c, config.Default())
+	}
+}
+
+func TestLoadFileAndOverrides(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "ctf.json", []byte(`{
+		"paths": {"streamers": "ctf.csv", "index": "ctf.md"},
+		"window_days": 7,
+		"min_hours": 2,
+		"timeout": "10m"
+	}`), 0o644)
+	t.Setenv("SECINFO_MIN_HOURS", "4.5")
+	t.Setenv("SECINFO_EXPORTS", "m3u, opml")
+	t.Setenv("SECINFO_TEST", "yes")
+	t.Setenv("SECINFO_REQUEST_TIMEOUT", "5s")
+	t.Setenv("TWITCH_CLIENT_ID", "id")
+	t.Setenv("TWITCH_TOKEN", "token")
+
+	c, err := config.Load(fs, "ctf.json")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if c.Paths.Streamers != "ctf.csv" || c.Paths.Index != "ctf.md" || c.Paths.Inactive != "inactive_streamers.csv" {
+		t.Errorf("Got: %+v, Wanted the file's paths on top of the defaults", c.Paths)
+	}
+	if c.WindowDays != 7 || c.MinHours != 4.5 || !reflect.DeepEqual(c.Exports, []string{"m3u", "opml"}) {
+		t.Errorf("Got: window %d, min hours %v, exports %q", c.WindowDays, c.MinHours, c.Exports)
+	}
+	if c.Active(4.5) || !c.Active(5) {
+		t.Errorf("Got: active at 4.5 %v, at 5 %v, Wanted: false, true", c.Active(4.5), c.Active(5))
+	}
+	if c.Timeout != config.Duration(10*time.Minute) || c.RequestTimeout != config.Duration(5*time.Second) {
+		t.Errorf("Got: timeout %v, request timeout %v, Wanted: 10m and 5s", c.Timeout, c.RequestTimeout)
+	}
+	if twitch, ok := c.ScheduleProvider().(calendar.Twitch); !c.Test || !ok || twitch.ClientID != "id" || twitch.Token != "token" {
+		t.Errorf("Got: test %v, schedules from %+v, Wanted test mode and the Twitch credentials", c.Test, c.ScheduleProvider())
+	}
+
+	providers, err := c.StatsProviders()
+	if err != nil {
+		t.Fatalf("StatsProviders failed: %v", err)
+	}
+	want := streamers.SullyGnome{BaseURL: streamers.SullyGnomeURL, UserAgent: streamers.DefaultUserAgent, WindowDays: 7}
+	got, ok := providers[0].(streamers.SullyGnome)
+	if len(providers) != 1 || !ok || got.Client == nil {
+		t.Fatalf("Got: %+v, Wanted a SullyGnome provider with a client", providers)
+	}
+	if got.Client = nil; got != want {
+		t.Errorf("Got: %+v, Wanted: %+v", got, want)
+	}
+}
+
+func TestLoadFail(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	if _, err := config.Load(fs, "missing.json"); err == nil {
+		t.Errorf("a missing config that was asked for should fail")
+	}
+
+	afero.WriteFile(fs, "typo.json", []byte(`{"window_dayz": 7}`), 0o644)
+	if _, err := config.Load(fs, "typo.json"); err == nil || !strings.Contains(err.Error(), "window_dayz") {
+		t.Errorf("Got: %v, Wanted an unknown field error", err)
+	}
+
+	afero.WriteFile(fs, "seconds.json", []byte(`{"timeout": 600}`), 0o644)
+	if _, err := config.Load(fs, "seconds.json"); err == nil || !strings.Contains(err.Error(), `"30s"`) {
+		t.Errorf("Got: %v, Wanted a duration error", err)
+	}
+
+	t.Setenv("SECINFO_WINDOW_DAYS", "a week")
+	if _, err := config.Load(fs, ""); err == nil || !strings.Contains(err.Error(), "SECINFO_WINDOW_DAYS") {
+		t.Errorf("Got: %v, Wanted a SECINFO_WINDOW_DAYS error", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for _, file := range []string{"streamers.csv", "templates/index.tmpl.md", "templates/inactive.tmpl.md", "templates/links.tmpl"} {
+		afero.WriteFile(fs, file, nil, 0o644)
+	}
+	if err := config.Default().Validate(fs); err != nil {
+		t.Fatalf("Got: %v, Wanted the defaults to be valid", err)
+	}
+
+	c := config.Default()
+	c.Paths.Feed = ""
+	c.Paths.IndexTemplate = "templates/missing.tmpl.md"
+	c.UserAgent = " "
+	c.WindowDays = 31
+	c.MinHours = -1
+	c.Providers = []string{"twitchtracker"}
+	c.SullyGnomeURL = "sullygnome.com"
+	c.Exports = []string{"csv"}
+	c.CategoryTags = map[string]string{"Just Chatting": "chatting"}
+	c.Sort.HTML = "random"
+	c.Paths.Profiles = "streamers"
+	c.Record = true
+	c.RequestTimeout = -1
+	c.MaxFailures = 150
+	c.FailurePolicy = "retry"
+
+	err := c.Validate(fs)
+	if err == nil {
+		t.Fatalf("invalid config passed")
+	}
+	for _, want := range []string{"paths.feed", "missing.tmpl.md", "user_agent", "window_days", "min_hours", "twitchtracker", "sullygnome_url", `"csv"`, `unknown tag "chatting"`, `sort.html "random"`, "streamer.tmpl.md", "record is set without a cassette", "request_timeout -1ns", "max_failures is 150", `failure_policy "retry"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Got: %v, Wanted it to mention %s", err, want)
+		}
+	}
+}
+
+func TestTooManyFailures(t *testing.T) {
+	c := config.Default()
+	for _, test := range []struct {
+		max             float64
+		failed, lookups int
+		want            bool
+	}{{50, 1, 2, false}, {50, 2, 3, true}, {0, 1, 100, true}, {0, 0, 0, false}, {100, 10, 10, false}} {
+		c.MaxFailures = test.max
+		if got := c.TooManyFailures(test.failed, test.lookups); got != test.want {
+			t.Errorf("%v%% with %d of %d failed: Got: %v, Wanted: %v", test.max, test.failed, test.lookups, got, test.want)
+		}
+	}
+}
+
+func TestHTTPClient(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for _, file := range []string{"streamers.csv", "templates/index.tmpl.md", "templates/inactive.tmpl.md", "templates/links.tmpl"} {
+		afero.WriteFile(fs, file, nil, 0o644)
+	}
+	c := config.Default()
+	if client := c.HTTPClient(); client.Transport != nil || client.Timeout != 30*time.Second {
+		t.Errorf("Got: %+v, Wanted the default transport with a 30s timeout without a cassette", client)
+	}
+
+	c.Cassette = "cassette"
+	if err := c.Validate(fs); err == nil || !strings.Contains(err.Error(), `cassette "cassette"`) {
+		t.Errorf("Got: %v, Wanted a missing cassette error", err)
+	}
+	fs.MkdirAll("cassette", 0o755)
+	if err := c.Validate(fs); err != nil {
+		t.Errorf("Got: %v, Wanted the cassette to be valid", err)
+	}
+	transport, ok := c.HTTPClient().Transport.(*replay.Transport)
+	if !ok || transport.Dir != "cassette" || transport.Mode != replay.Replay {
+		t.Errorf("Got: %+v, Wanted a transport replaying the cassette", c.HTTPClient().Transport)
+	}
+}
+
+func TestLoadLists(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "secinfo.json", []byte(`{
+		"title": "Streams",
+		"lists": [
+			{"name": "infosec", "paths": {"streamers": "streamers.csv"}},
+			{"name": "ctf", "title": "CTF Streams", "min_hours": 1, "sort": {"index": "live"}}
+		]
+	}`), 0o644)
+
+	c, err := config.Load(fs, "")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	lists := c.AllLists()
+	if len(lists) != 2 {
+		t.Fatalf("Got: %d lists, Wanted: 2", len(lists))
+	}
+	if lists[0].Paths.Streamers != "streamers.csv" || lists[0].Paths.Index != "infosec/index.md" || lists[0].Title != "Streams" {
+		t.Errorf("Got: %+v, Wanted the default paths inside infosec/ and the top level title", lists[0])
+	}
+	if lists[1].Paths.IndexTemplate != "ctf/templates/index.tmpl.md" || lists[1].Title != "CTF Streams" || lists[1].Active(1) {
+		t.Errorf("Got: %+v, Wanted the default paths inside ctf/ and its own title and threshold", lists[1])
+	}
+	if lists[1].Sort.Index != "live" || lists[1].Sort.Inactive != "name" || lists[0].Sort.Index != "hours" {
+		t.Errorf("Got: %+v and %+v, Wanted the ctf index sorted by live and the defaults otherwise", lists[0].Sort, lists[1].Sort)
+	}
+	if single := config.Default().AllLists(); len(single) != 1 || single[0].Paths.Index != "index.md" {
+		t.Errorf("Got: %+v, Wanted the top level list", single)
+	}
+}
+
+func TestValidateTestMode(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for _, file := range []string{"templates/index.tmpl.md", "templates/inactive.tmpl.md", "templates/links.tmpl"} {
+		afero.WriteFile(fs, file, nil, 0o644)
+	}
+	c := config.Default()
+	if err := c.Validate(fs); err == nil || !strings.Contains(err.Error(), "streamers.csv") {
+		t.Errorf("Got: %v, Wanted the missing streamers.csv reported", err)
+	}
+	// Test runs reuse the last run's json, they never read the csv
+	c.Test = true
+	if err := c.Validate(fs); err != nil {
+		t.Errorf("Got: %v, Wanted test mode to run without streamers.csv", err)
+	}
+}
+
+func TestValidateLists(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	c := config.Default()
+	c.Lists = []config.List{c.List, c.List, {Name: "ctf", SiteURL: "https://ctf.example.com"}}
+	c.Lists[0].Name, c.Lists[1].Name = "infosec", "infosec"
+
+	err := c.Validate(fs)
+	if err == nil {
+		t.Fatalf("invalid lists passed")
+	}
+	for _, want := range []string{`lists[1].name "infosec" is used`, `lists[1].paths.index "index.md" is also lists[0].paths.index`, "lists[2].paths.feed is empty"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Got: %v, Wanted it to mention %s", err, want)
+		}
+	}
+}
+
+func TestCategories(t *testing.T) {
+	c := config.Default()
+	if got := c.Categories(); !reflect.DeepEqual(got, streamers.DefaultCategoryTags) {
+		t.Errorf("Got: %v, Wanted the default categories", got)
+	}
+	c.CategoryTags = map[string]string{"Science & Technology": "Blue Team"}
+	if got := c.Categories(); len(got) != 1 || got["Science & Technology"] != streamers.BlueTeam {
+		t.Errorf("Got: %v, Wanted: Science & Technology is blue-team", got)
+	}
+}
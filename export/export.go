@@ -0,0 +1,78 @@
+/*
+Package export writes the active streamer list in formats other tools import.
+
+Exporters register themselves by name, usually from an init func, and every registered
+exporter is run unless the caller picks a subset. Adding a format doesn't touch main.
+*/
+package export
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/infosecstreams/secinfo/output"
+	"github.com/infosecstreams/secinfo/streamers"
+)
+
+// Exporter renders a streamer list in one format.
+type Exporter interface {
+	Filename() string                                               // The file the export is written to, e.g. twitch.m3u
+	Export(sl streamers.StreamerList, title string) ([]byte, error) // The exported list, title names the list
+}
+
+var (
+	mu        sync.RWMutex
+	exporters = map[string]Exporter{}
+)
+
+// Register makes an Exporter available by name. It panics if name is already registered.
+func Register(name string, e Exporter) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, dup := exporters[name]; dup {
+		panic("export: Register called twice for " + name)
+	}
+	exporters[name] = e
+}
+
+// Names returns the names of every registered Exporter, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(exporters))
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the Exporter registered as name.
+func Get(name string) (Exporter, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	e, ok := exporters[name]
+	return e, ok
+}
+
+// Render queues the exports of sl, the list called title, named by names into dir in b.
+// No names runs every registered Exporter.
+func Render(b *output.Batch, dir, title string, sl streamers.StreamerList, names ...string) error {
+	if len(names) == 0 {
+		names = Names()
+	}
+	for _, name := range names {
+		e, ok := Get(name)
+		if !ok {
+			return fmt.Errorf("unknown export %q, have %v", name, Names())
+		}
+		data, err := e.Export(sl, title)
+		if err != nil {
+			return fmt.Errorf("export %s: %w", name, err)
+		}
+		b.Add(path.Join(dir, e.Filename()), data)
+	}
+	return nil
+}
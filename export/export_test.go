@@ -0,0 +1,92 @@
+package export_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/infosecstreams/secinfo/export"
+	"github.com/infosecstreams/secinfo/output"
+	"github.com/infosecstreams/secinfo/streamers"
+)
+
+type csvExporter struct{}
+
+func (csvExporter) Filename() string { return "names.txt" }
+
+func (csvExporter) Export(sl streamers.StreamerList, title string) ([]byte, error) {
+	var names []string
+	for _, s := range sl.Streamers {
+		names = append(names, s.Name)
+	}
+	return []byte(strings.Join(names, "\n")), nil
+}
+
+func testList() streamers.StreamerList {
+	alice := streamers.Streamer{Name: "alice"}
+	alice.SetAccount(streamers.Account{Platform: streamers.Twitch, Handle: "alice"})
+	alice.SetAccount(streamers.Account{Platform: streamers.YouTube, URL: "https://www.youtube.com/channel/UC123"})
+	bob := streamers.Streamer{Name: "bob & co"}
+	bob.SetAccount(streamers.Account{Platform: streamers.Twitch, Handle: "bob"})
+	bob.SetAccount(streamers.Account{Platform: streamers.YouTube, Handle: "bob"})
+	carol := streamers.Streamer{Name: "carol"}
+	carol.SetAccount(streamers.Account{Platform: streamers.Owncast, URL: "https://live.example.com"})
+	return streamers.StreamerList{Streamers: []streamers.Streamer{alice, bob, carol}}
+}
+
+func TestRegistry(t *testing.T) {
+	export.Register("names", csvExporter{})
+	if got, want := export.Names(), []string{"m3u", "names", "opml"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got: %v, Wanted: %v", got, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("registering a name twice should panic")
+		}
+	}()
+	export.Register("names", csvExporter{})
+}
+
+func TestRender(t *testing.T) {
+	var b output.Batch
+	if err := export.Render(&b, "export", "CTF Streams", testList(), "m3u", "opml"); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	files := map[string]string{}
+	b.Each(func(path string, data []byte) { files[path] = string(data) })
+
+	wantM3U := "#EXTM3U\n#EXTINF:-1,alice\nhttps://www.twitch.tv/alice\n#EXTINF:-1,bob & co\nhttps://www.twitch.tv/bob\n"
+	if files["export/twitch.m3u"] != wantM3U {
+		t.Errorf("Got: %q, Wanted: %q", files["export/twitch.m3u"], wantM3U)
+	}
+
+	opml := files["export/youtube.opml"]
+	if !strings.Contains(opml, `<outline type="rss" text="alice" title="alice" xmlUrl="https://www.youtube.com/feeds/videos.xml?channel_id=UC123" htmlUrl="https://www.youtube.com/channel/UC123"></outline>`) {
+		t.Errorf("opml is missing alice:\n%s", opml)
+	}
+	if !strings.Contains(opml, "<title>CTF Streams YouTube channels</title>") {
+		t.Errorf("opml isn't titled after the list:\n%s", opml)
+	}
+	if strings.Contains(opml, "bob") {
+		t.Errorf("bob has no channel ID and should be skipped:\n%s", opml)
+	}
+
+	if err := export.Render(&b, "export", "CTF Streams", testList(), "nope"); err == nil {
+		t.Errorf("unknown exports should fail")
+	}
+}
+
+func TestYouTubeChannelID(t *testing.T) {
+	for raw, want := range map[string]string{
+		"https://www.youtube.com/channel/UC123":        "UC123",
+		"https://youtube.com/channel/UC123/videos":     "UC123",
+		"https://www.youtube.com/@alice":               "",
+		"https://example.com/channel/UC123":            "",
+		"https://www.youtube.com/c/SomeCustomName/abc": "",
+	} {
+		if got := export.YouTubeChannelID(raw); got != want {
+			t.Errorf("YouTubeChannelID(%q) Got: %q, Wanted: %q", raw, got, want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package export
+
+import (
+	"encoding/xml"
+	"net/url"
+	"strings"
+
+	"github.com/infosecstreams/secinfo/streamers"
+)
+
+func init() {
+	Register("opml", OPML{})
+	Register("m3u", M3U{})
+}
+
+// OPML exports the YouTube channels' video feeds as an OPML subscription list for RSS readers.
+// Only YouTube urls with a channel ID (youtube.com/channel/UC...) have a feed, others are skipped.
+type OPML struct{}
+
+// Filename returns youtube.opml.
+func (OPML) Filename() string {
+	return "youtube.opml"
+}
+
+type opmlDoc struct {
+	XMLName xml.Name      `xml:"opml"`
+	Version string        `xml:"version,attr"`
+	Title   string        `xml:"head>title"`
+	Outline []opmlOutline `xml:"body>outline"`
+}
+
+type opmlOutline struct {
+	Type    string `xml:"type,attr"`
+	Text    string `xml:"text,attr"`
+	Title   string `xml:"title,attr"`
+	XMLURL  string `xml:"xmlUrl,attr"`
+	HTMLURL string `xml:"htmlUrl,attr"`
+}
+
+// Export returns the OPML document, titled after the list.
+func (OPML) Export(sl streamers.StreamerList, title string) ([]byte, error) {
+	doc := opmlDoc{Version: "2.0", Title: strings.TrimSpace(title + " YouTube channels")}
+	for _, s := range sl.Streamers {
+		channel := YouTubeChannelID(s.YouTubeURL())
+		if channel == "" {
+			continue
+		}
+		doc.Outline = append(doc.Outline, opmlOutline{
+			Type:    "rss",
+			Text:    s.Name,
+			Title:   s.Name,
+			XMLURL:  "https://www.youtube.com/feeds/videos.xml?channel_id=" + channel,
+			HTMLURL: s.YouTubeURL(),
+		})
+	}
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(out, '\n')...), nil
+}
+
+// YouTubeChannelID returns the channel ID of a youtube.com/channel/<id> url, or an empty string.
+func YouTubeChannelID(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || !strings.HasSuffix(u.Hostname(), "youtube.com") {
+		return ""
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "channel" {
+		return ""
+	}
+	return parts[1]
+}
+
+// M3U exports the Twitch channels as an extended M3U playlist for players like mpv or VLC.
+type M3U struct{}
+
+// Filename returns twitch.m3u.
+func (M3U) Filename() string {
+	return "twitch.m3u"
+}
+
+// Export returns the playlist.
+func (M3U) Export(sl streamers.StreamerList, title string) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	for _, s := range sl.Streamers {
+		a, ok := s.Account(streamers.Twitch)
+		if !ok {
+			continue
+		}
+		b.WriteString("#EXTINF:-1," + s.Name + "\n")
+		b.WriteString(a.URL + "\n")
+	}
+	return []byte(b.String()), nil
+}
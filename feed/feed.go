@@ -0,0 +1,156 @@
+/* Package feed keeps an Atom feed of changes to the streamer lists. */
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/infosecstreams/secinfo/changes"
+	"github.com/infosecstreams/secinfo/streamers"
+)
+
+// DefaultMaxEntries is how many entries a feed keeps when Options.MaxEntries isn't set.
+const DefaultMaxEntries = 50
+
+// Options describe the feed being updated.
+type Options struct {
+	Title      string // The feed's title, also the list's name in entries
+	SiteURL    string // The url of the site the feed is for, e.g. https://infosecstreams.com
+	Path       string // The feed's path on the site, e.g. atom.xml
+	MaxEntries int    // Entries to keep, oldest are dropped first
+	WindowDays int    // Days of activity the hours cover, streamers.WindowDays if zero
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title    string       `xml:"title"`
+	ID       string       `xml:"id"`
+	Updated  string       `xml:"updated"`
+	Links    []atomLink   `xml:"link"`
+	Category atomCategory `xml:"category"`
+	Summary  string       `xml:"summary"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// Update adds an entry per event to the front of the existing feed and returns the new feed.
+// An empty existing starts a new feed. The feed's updated time only moves when entries are added,
+// so runs without changes leave the file as it was.
+func Update(existing []byte, events []changes.Event, now time.Time, opts Options) ([]byte, error) {
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = DefaultMaxEntries
+	}
+	if opts.WindowDays == 0 {
+		opts.WindowDays = streamers.WindowDays
+	}
+	site, err := url.Parse(opts.SiteURL)
+	if err != nil {
+		return nil, fmt.Errorf("bad site url: %w", err)
+	}
+	feedURL := strings.TrimSuffix(opts.SiteURL, "/") + "/" + strings.TrimPrefix(opts.Path, "/")
+	stamp := now.UTC().Format(time.RFC3339)
+
+	f := atomFeed{Updated: stamp}
+	if len(bytes.TrimSpace(existing)) > 0 {
+		if err := xml.Unmarshal(existing, &f); err != nil {
+			return nil, fmt.Errorf("reading existing feed: %w", err)
+		}
+	}
+	f.Title = opts.Title
+	f.ID = feedURL
+	f.Author = atomAuthor{Name: opts.Title}
+	f.Links = []atomLink{{Href: feedURL, Rel: "self"}, {Href: opts.SiteURL}}
+
+	var entries []atomEntry
+	for _, e := range events {
+		entries = append(entries, newEntry(e, site.Host, now, opts))
+	}
+	if len(entries) > 0 {
+		f.Updated = stamp
+	}
+
+	seen := map[string]bool{}
+	var kept []atomEntry
+	for _, e := range append(entries, f.Entries...) {
+		if seen[e.ID] || len(kept) == opts.MaxEntries {
+			continue
+		}
+		seen[e.ID] = true
+		kept = append(kept, e)
+	}
+	f.Entries = kept
+
+	out, err := xml.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(out, '\n')...), nil
+}
+
+func newEntry(e changes.Event, host string, now time.Time, opts Options) atomEntry {
+	s := e.Streamer
+	link := opts.SiteURL
+	if len(s.Accounts) > 0 {
+		link = s.Accounts[0].URL
+	}
+
+	var title, summary string
+	switch e.Kind {
+	case changes.Added:
+		title = s.Name + " was added to the list"
+		summary = fmt.Sprintf("%s is now listed on %s.", s.Name, opts.Title)
+	case changes.Removed:
+		title = s.Name + " was removed from the list"
+		summary = fmt.Sprintf("%s is no longer listed on %s.", s.Name, opts.Title)
+	case changes.Promoted:
+		title = s.Name + " is active again"
+		summary = fmt.Sprintf("%s streamed %.1f hours in the last %d days and moved back to the active list.", s.Name, s.ThirtyDayStats, opts.WindowDays)
+	case changes.Demoted:
+		title = s.Name + " moved to the inactive list"
+		summary = fmt.Sprintf("%s hasn't streamed recently and moved to the inactive list.", s.Name)
+	case changes.WentLive:
+		title = s.Name + " is live"
+		summary = fmt.Sprintf("%s just went live.", s.Name)
+		if s.Lang != "" {
+			summary = fmt.Sprintf("%s just went live (%s).", s.Name, s.Lang)
+		}
+	}
+
+	id := fmt.Sprintf("tag:%s,%s:%s/%s", host, now.UTC().Format("2006-01-02"), e.Kind, strings.ToLower(s.Name))
+	if e.Kind == changes.WentLive {
+		// A streamer can go live more than once a day
+		id += "/" + now.UTC().Format("150405")
+	}
+	return atomEntry{
+		Title:    title,
+		ID:       id,
+		Updated:  now.UTC().Format(time.RFC3339),
+		Links:    []atomLink{{Href: link}},
+		Category: atomCategory{Term: string(e.Kind)},
+		Summary:  summary,
+	}
+}
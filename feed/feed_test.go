@@ -0,0 +1,119 @@
+package feed_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/infosecstreams/secinfo/changes"
+	"github.com/infosecstreams/secinfo/feed"
+	"github.com/infosecstreams/secinfo/streamers"
+)
+
+var opts = feed.Options{Title: "InfoSec Streams", SiteURL: "https://infosecstreams.com", Path: "atom.xml"}
+
+type parsed struct {
+	Updated string `xml:"updated"`
+	Entries []struct {
+		Title   string `xml:"title"`
+		ID      string `xml:"id"`
+		Summary string `xml:"summary"`
+		Link    struct {
+			Href string `xml:"href,attr"`
+		} `xml:"link"`
+	} `xml:"entry"`
+}
+
+func parse(t *testing.T, data []byte) parsed {
+	t.Helper()
+
+	var p parsed
+	if err := xml.Unmarshal(data, &p); err != nil {
+		t.Fatalf("feed is not xml: %v\n%s", err, data)
+	}
+	return p
+}
+
+func TestUpdate(t *testing.T) {
+	alice := streamers.Streamer{Name: "Alice", ThirtyDayStats: 4}
+	alice.SetAccount(streamers.Account{Platform: streamers.Twitch, Handle: "Alice"})
+	day1 := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+
+	first, err := feed.Update(nil, []changes.Event{{Kind: changes.Added, Streamer: alice}}, day1, opts)
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if !strings.HasPrefix(string(first), xml.Header) || !strings.Contains(string(first), `<feed xmlns="http://www.w3.org/2005/Atom">`) {
+		t.Fatalf("not an atom feed:\n%s", first)
+	}
+	if !strings.Contains(string(first), `<link href="https://infosecstreams.com/atom.xml" rel="self"></link>`) {
+		t.Fatalf("missing self link:\n%s", first)
+	}
+	ctf := opts
+	ctf.Title = "CTF Streams"
+	if added, _ := feed.Update(nil, []changes.Event{{Kind: changes.Added, Streamer: alice}}, day1, ctf); parse(t, added).Entries[0].Summary != "Alice is now listed on CTF Streams." {
+		t.Errorf("Got: %q, Wanted the list's title in the summary", parse(t, added).Entries[0].Summary)
+	}
+
+	second, err := feed.Update(first, []changes.Event{
+		{Kind: changes.Promoted, Streamer: alice},
+		{Kind: changes.WentLive, Streamer: alice},
+	}, day2, opts)
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	p := parse(t, second)
+	if len(p.Entries) != 3 {
+		t.Fatalf("Got: %d entries, Wanted: 3", len(p.Entries))
+	}
+	wantTitles := []string{"Alice is active again", "Alice is live", "Alice was added to the list"}
+	for i, want := range wantTitles {
+		if p.Entries[i].Title != want {
+			t.Errorf("entry %d Got: %q, Wanted: %q", i, p.Entries[i].Title, want)
+		}
+	}
+	if p.Entries[0].ID != "tag:infosecstreams.com,2026-10-19:promoted/alice" {
+		t.Errorf("Got: %q", p.Entries[0].ID)
+	}
+	if p.Entries[0].Link.Href != "https://www.twitch.tv/Alice" {
+		t.Errorf("Got: %q", p.Entries[0].Link.Href)
+	}
+	if p.Updated != "2026-10-19T12:00:00Z" {
+		t.Errorf("Got: %q", p.Updated)
+	}
+
+	// Nothing changed, the feed stays byte for byte the same
+	third, err := feed.Update(second, nil, day2.Add(time.Hour), opts)
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if string(third) != string(second) {
+		t.Fatalf("feed changed without events:\n%s", third)
+	}
+}
+
+func TestUpdateMaxEntries(t *testing.T) {
+	o := opts
+	o.MaxEntries = 2
+	var data []byte
+	now := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	for _, name := range []string{"a", "b", "c"} {
+		var err error
+		data, err = feed.Update(data, []changes.Event{{Kind: changes.Added, Streamer: streamers.Streamer{Name: name}}}, now, o)
+		if err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+	p := parse(t, data)
+	if len(p.Entries) != 2 || p.Entries[0].Title != "c was added to the list" || p.Entries[1].Title != "b was added to the list" {
+		t.Fatalf("oldest entries should be dropped: %+v", p.Entries)
+	}
+}
+
+func TestUpdateFail(t *testing.T) {
+	if _, err := feed.Update([]byte("<feed"), nil, time.Now(), opts); err == nil {
+		t.Fatalf("Update should fail on a broken feed")
+	}
+}
@@ -2,6 +2,7 @@ module github.com/infosecstreams/secinfo
 
 go 1.26
 
-require github.com/spf13/afero v1.15.0
-
-require golang.org/x/text v0.34.0 // indirect
+require (
+	github.com/spf13/afero v1.15.0
+	golang.org/x/text v0.34.0
+)
@@ -0,0 +1,259 @@
+/* Package history keeps every streamer's hours and rank over time, one point per day, so pages can show who is trending. */
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/infosecstreams/secinfo/changes"
+	"github.com/spf13/afero"
+)
+
+// MaxPoints is how many days of points a streamer keeps, older ones are dropped.
+const MaxPoints = 90
+
+// TrendPoints is how many of the latest points a trend line shows.
+const TrendPoints = 8
+
+// Point is a streamer's stats as of one day.
+type Point struct {
+	Time  time.Time `json:"time"`           // When the point was recorded
+	Hours float32   `json:"hours"`          // Hours streamed in the stats window
+	Rank  int       `json:"rank,omitempty"` // 1-based position on the active list by hours, 0 if inactive
+}
+
+// Week counts the runs a streamer was seen live in, by UTC weekday and hour.
+type Week [7][24]int
+
+// History is the points of every streamer on a list, oldest first.
+type History struct {
+	Since     time.Time          `json:"since,omitzero"` // When the first point was recorded
+	Streamers map[string][]Point `json:"streamers"`      // Points by streamer name, lower case
+	Live      map[string]*Week   `json:"live,omitempty"` // When each streamer was seen live, by streamer name, lower case
+}
+
+// Load reads the history file at path. A missing file is an empty History.
+func Load(fileSystem afero.Fs, path string) (History, error) {
+	h := History{Streamers: map[string][]Point{}}
+	data, err := afero.ReadFile(fileSystem, path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return h, err
+	}
+	if err := json.Unmarshal(data, &h); err != nil {
+		return h, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if h.Streamers == nil {
+		h.Streamers = map[string][]Point{}
+	}
+	if h.Live == nil {
+		h.Live = map[string]*Week{}
+	}
+	return h, nil
+}
+
+// Record adds a point for every streamer in run as of now, ranking the active list in its order, and counts
+// the streamers that are online as seen live now.
+// A point recorded on the same day as a streamer's last one replaces it, so several runs a day keep one point.
+// Streamers no longer on either list are forgotten.
+func (h *History) Record(run changes.Run, now time.Time) {
+	if h.Streamers == nil {
+		h.Streamers = map[string][]Point{}
+	}
+	if h.Live == nil {
+		h.Live = map[string]*Week{}
+	}
+	if h.Since.IsZero() {
+		h.Since = now
+	}
+	seen := map[string]bool{}
+	add := func(name string, p Point) {
+		key := strings.ToLower(name)
+		seen[key] = true
+		points := h.Streamers[key]
+		if n := len(points); n > 0 && sameDay(points[n-1].Time, now) {
+			points = points[:n-1]
+		}
+		points = append(points, p)
+		if len(points) > MaxPoints {
+			points = points[len(points)-MaxPoints:]
+		}
+		h.Streamers[key] = points
+	}
+	for i, s := range run.Active {
+		add(s.Name, Point{Time: now, Hours: s.ThirtyDayStats, Rank: i + 1})
+		if s.Online {
+			key := strings.ToLower(s.Name)
+			if h.Live[key] == nil {
+				h.Live[key] = &Week{}
+			}
+			h.Live[key][now.UTC().Weekday()][now.UTC().Hour()]++
+		}
+	}
+	for _, s := range run.Inactive {
+		add(s.Name, Point{Time: now, Hours: s.ThirtyDayStats})
+	}
+	for key := range h.Streamers {
+		if !seen[key] {
+			delete(h.Streamers, key)
+			delete(h.Live, key)
+		}
+	}
+}
+
+// Seen returns when the streamer has been seen live, nil if never.
+func (h History) Seen(name string) *Week {
+	return h.Live[strings.ToLower(name)]
+}
+
+// Usual returns the days and hours, UTC, the streamer is live on at least half as often as on their busiest.
+// Both are empty if they've never been seen live. Only the moments runs happened are seen, so a streamer live
+// between runs isn't counted: with a run a day they're the streamer's habits at that time of day, at best.
+func (w *Week) Usual() (days []time.Weekday, hours []int) {
+	if w == nil {
+		return nil, nil
+	}
+	var perDay [7]int
+	var perHour [24]int
+	for d := range w {
+		for hour, n := range w[d] {
+			perDay[d] += n
+			perHour[hour] += n
+		}
+	}
+	busiestDay, busiestHour := slices.Max(perDay[:]), slices.Max(perHour[:])
+	for d, n := range perDay {
+		if n > 0 && 2*n >= busiestDay {
+			days = append(days, time.Weekday(d))
+		}
+	}
+	for hour, n := range perHour {
+		if n > 0 && 2*n >= busiestHour {
+			hours = append(hours, hour)
+		}
+	}
+	return days, hours
+}
+
+// Runs returns how many runs saw the streamer live, the only moments the usual days and hours are known from.
+func (w *Week) Runs() int {
+	if w == nil {
+		return 0
+	}
+	n := 0
+	for d := range w {
+		for _, count := range w[d] {
+			n += count
+		}
+	}
+	return n
+}
+
+// Points returns the streamer's points, oldest first.
+func (h History) Points(name string) []Point {
+	return h.Streamers[strings.ToLower(name)]
+}
+
+// Ranks returns the streamer's rank as of the last point and the point before it.
+// known is false if the history doesn't go back before the last point's day at all, i.e. there's nothing to compare with.
+func (h History) Ranks(name string) (rank, previous int, known bool) {
+	points := h.Points(name)
+	if len(points) == 0 {
+		return 0, 0, false
+	}
+	last := points[len(points)-1]
+	if len(points) > 1 {
+		return last.Rank, points[len(points)-2].Rank, true
+	}
+	return last.Rank, 0, !sameDay(h.Since, last.Time)
+}
+
+// Trend returns a sparkline of the streamer's hours over their last TrendPoints points, empty without two to compare.
+func (h History) Trend(name string) string {
+	points := h.Points(name)
+	if len(points) < 2 {
+		return ""
+	}
+	if len(points) > TrendPoints {
+		points = points[len(points)-TrendPoints:]
+	}
+	hours := make([]float32, len(points))
+	for i, p := range points {
+		hours[i] = p.Hours
+	}
+	return Sparkline(hours)
+}
+
+// Total is the hours a whole list streamed in a week.
+type Total struct {
+	Week  time.Time // The Monday the week starts on, UTC
+	Hours float32   // Hours streamed that week
+}
+
+// WeeklyHours returns the hours every streamer together streamed each week, oldest first.
+// The stats window covers windowDays days, so a week's hours are estimated from each streamer's last point that
+// week as Hours * 7 / windowDays.
+func (h History) WeeklyHours(windowDays int) []Total {
+	last := map[string]map[time.Time]Point{}
+	for name, points := range h.Streamers {
+		last[name] = map[time.Time]Point{}
+		for _, p := range points {
+			last[name][weekOf(p.Time)] = p
+		}
+	}
+	sums := map[time.Time]float32{}
+	for _, name := range slices.Sorted(maps.Keys(last)) { // In order, so the float sums are the same every run
+		for week, p := range last[name] {
+			sums[week] += p.Hours * 7 / float32(windowDays)
+		}
+	}
+	var totals []Total
+	for week, hours := range sums {
+		totals = append(totals, Total{Week: week, Hours: hours})
+	}
+	slices.SortFunc(totals, func(a, b Total) int { return a.Week.Compare(b.Week) })
+	return totals
+}
+
+// weekOf returns the start of the Monday of t's week, UTC.
+func weekOf(t time.Time) time.Time {
+	day := t.UTC().Truncate(24 * time.Hour)
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
+
+// bars are the sparkline's levels, lowest first.
+var bars = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws values as a line of bars scaled between their lowest and highest value.
+// Values that are all the same are drawn at the lowest level.
+func Sparkline(values []float32) string {
+	if len(values) == 0 {
+		return ""
+	}
+	low, high := values[0], values[0]
+	for _, v := range values {
+		low, high = min(low, v), max(high, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		level := 0
+		if high > low {
+			level = int((v - low) / (high - low) * float32(len(bars)-1))
+		}
+		b.WriteRune(bars[level])
+	}
+	return b.String()
+}
+
+// sameDay reports whether a and b are on the same UTC day.
+func sameDay(a, b time.Time) bool {
+	return a.UTC().Truncate(24 * time.Hour).Equal(b.UTC().Truncate(24 * time.Hour))
+}
@@ -0,0 +1,134 @@
+package history_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/infosecstreams/secinfo/changes"
+	"github.com/infosecstreams/secinfo/history"
+	"github.com/infosecstreams/secinfo/output"
+	"github.com/infosecstreams/secinfo/streamers"
+	"github.com/spf13/afero"
+)
+
+func TestRecord(t *testing.T) {
+	day := time.Date(2024, 5, 10, 8, 0, 0, 0, time.UTC)
+	run := changes.Run{
+		Active:   []streamers.Streamer{{Name: "Alice", ThirtyDayStats: 9}, {Name: "bob", ThirtyDayStats: 5}},
+		Inactive: []streamers.Streamer{{Name: "carol"}},
+	}
+
+	var h history.History
+	h.Record(run, day)
+	run.Active[0].ThirtyDayStats = 10
+	h.Record(run, day.Add(6*time.Hour)) // Same day, replaces the morning's point
+	run.Active[0], run.Active[1] = run.Active[1], run.Active[0]
+	run.Inactive = nil
+	h.Record(run, day.Add(24*time.Hour))
+
+	points := h.Points("alice")
+	if len(points) != 2 || points[0].Hours != 10 || points[0].Rank != 1 || points[1].Rank != 2 {
+		t.Errorf("Got: %+v, Wanted a point per day with alice's ranks 1 and 2", points)
+	}
+	if rank, previous, known := h.Ranks("bob"); rank != 1 || previous != 2 || !known {
+		t.Errorf("Got: %d, %d, %v, Wanted: 1, 2, true", rank, previous, known)
+	}
+	if points := h.Points("carol"); points != nil {
+		t.Errorf("Got: %+v, Wanted carol forgotten once she's off both lists", points)
+	}
+	if !h.Since.Equal(day) {
+		t.Errorf("Got: %v, Wanted: %v", h.Since, day)
+	}
+}
+
+func TestRecordKeepsMaxPoints(t *testing.T) {
+	var h history.History
+	run := changes.Run{Active: []streamers.Streamer{{Name: "alice"}}}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range history.MaxPoints + 5 {
+		h.Record(run, start.AddDate(0, 0, i))
+	}
+	points := h.Points("alice")
+	if len(points) != history.MaxPoints || !points[0].Time.Equal(start.AddDate(0, 0, 5)) {
+		t.Errorf("Got: %d points from %v, Wanted: %d from %v", len(points), points[0].Time, history.MaxPoints, start.AddDate(0, 0, 5))
+	}
+}
+
+func TestRanksFirstDay(t *testing.T) {
+	day := time.Date(2024, 5, 10, 8, 0, 0, 0, time.UTC)
+	var h history.History
+	h.Record(changes.Run{Active: []streamers.Streamer{{Name: "alice"}}}, day)
+	if _, _, known := h.Ranks("alice"); known {
+		t.Errorf("Got: known, Wanted nothing to compare with on the first day")
+	}
+
+	h.Record(changes.Run{Active: []streamers.Streamer{{Name: "alice"}, {Name: "bob"}}}, day.Add(24*time.Hour))
+	if rank, previous, known := h.Ranks("bob"); rank != 2 || previous != 0 || !known {
+		t.Errorf("Got: %d, %d, %v, Wanted bob new at rank 2", rank, previous, known)
+	}
+}
+
+func TestUsual(t *testing.T) {
+	var h history.History
+	friday := time.Date(2024, 5, 10, 18, 0, 0, 0, time.UTC)
+	for _, at := range []time.Time{friday, friday.Add(time.Hour), friday.AddDate(0, 0, 7), friday.AddDate(0, 0, 8).Add(5 * time.Hour)} {
+		h.Record(changes.Run{Active: []streamers.Streamer{{Name: "Alice", Online: true}, {Name: "bob"}}}, at)
+	}
+
+	days, hours := h.Seen("alice").Usual()
+	if !reflect.DeepEqual(days, []time.Weekday{time.Friday}) || !reflect.DeepEqual(hours, []int{18, 19, 23}) {
+		t.Errorf("Got: %v and %v, Wanted: [Friday] and [18 19 23]", days, hours)
+	}
+	if n := h.Seen("alice").Runs(); n != 4 {
+		t.Errorf("Got: %d, Wanted: 4 runs that saw alice live", n)
+	}
+	if days, hours := h.Seen("bob").Usual(); days != nil || hours != nil {
+		t.Errorf("Got: %v and %v, Wanted nothing for a streamer never seen live", days, hours)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	for _, tt := range []struct {
+		values []float32
+		want   string
+	}{
+		{nil, ""},
+		{[]float32{3, 3}, "▁▁"},
+		{[]float32{0, 7, 14}, "▁▄█"},
+		{[]float32{10, 5, 0}, "█▄▁"},
+	} {
+		if got := history.Sparkline(tt.values); got != tt.want {
+			t.Errorf("Got: %q, Wanted: %q", got, tt.want)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	h, err := history.Load(fs, "history.json")
+	if err != nil || len(h.Streamers) != 0 {
+		t.Fatalf("Got: %v, %v, Wanted an empty history for a missing file", h, err)
+	}
+
+	h.Record(changes.Run{Active: []streamers.Streamer{{Name: "alice", ThirtyDayStats: 4}}}, time.Now())
+	var b output.Batch
+	if err := b.AddJSON("history.json", h); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Commit(fs); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := history.Load(fs, "history.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if points := loaded.Points("alice"); len(points) != 1 || points[0].Hours != 4 || points[0].Rank != 1 {
+		t.Errorf("Got: %+v, Wanted alice's point back", points)
+	}
+
+	afero.WriteFile(fs, "broken.json", []byte("{"), 0644)
+	if _, err := history.Load(fs, "broken.json"); err == nil {
+		t.Errorf("Got: nil, Wanted an error for malformed json")
+	}
+}
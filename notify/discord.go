@@ -0,0 +1,139 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/infosecstreams/secinfo/changes"
+)
+
+// Discord posts embeds to a Discord webhook.
+// See https://discord.com/developers/docs/resources/webhook#execute-webhook.
+type Discord struct {
+	WebhookURL string       // The webhook's url
+	Client     *http.Client // The client to post with, http.DefaultClient if nil
+}
+
+type discordMessage struct {
+	Username string         `json:"username,omitempty"`
+	Embeds   []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	URL         string         `json:"url,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Color       int            `json:"color,omitempty"`
+	Timestamp   string         `json:"timestamp,omitempty"`
+	Fields      []discordField `json:"fields,omitempty"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// discordDescriptionLimit is the most characters Discord accepts in an embed description.
+const discordDescriptionLimit = 4096
+
+var discordColors = map[changes.Kind]int{
+	changes.WentLive: 0x9146FF,
+	changes.Added:    0x3FB950,
+	changes.Promoted: 0x3FB950,
+	changes.Demoted:  0x9AA0A6,
+	changes.Removed:  0x9AA0A6,
+	KindDigest:       0x3FB950,
+}
+
+// Templates word Discord messages with markdown links.
+func (Discord) Templates() map[changes.Kind]string {
+	return map[changes.Kind]string{
+		changes.WentLive: `{{.Name}} has streamed {{printf "%.1f" .Hours}} hours in the last {{windowDays}} days.`,
+		KindDigest: `{{with .Added}}**Added**
+{{range .}}- [{{.Name}}]({{.URL}})
+{{end}}
+{{end}}{{with .Promoted}}**Back to active**
+{{range .}}- [{{.Name}}]({{.URL}}) ({{printf "%.1f" .Hours}}h)
+{{end}}
+{{end}}{{with .Demoted}}**Moved to inactive**
+{{range .}}- [{{.Name}}]({{.URL}})
+{{end}}
+{{end}}{{with .Removed}}**Removed**
+{{range .}}- {{.Name}}
+{{end}}
+{{end}}{{with .Live}}**Went live**
+{{range .}}- [{{.Name}}]({{.URL}})
+{{end}}{{end}}`,
+	}
+}
+
+// Send posts m as an embed, from a webhook named after m.List. Messages about a streamer get a field per platform account.
+func (d Discord) Send(ctx context.Context, m Message) error {
+	description := strings.TrimSpace(m.Text)
+	if runes := []rune(description); len(runes) > discordDescriptionLimit {
+		description = string(runes[:discordDescriptionLimit-1]) + "…"
+	}
+	embed := discordEmbed{
+		Title:       m.Title,
+		URL:         m.URL,
+		Description: description,
+		Color:       discordColors[m.Kind],
+		Timestamp:   m.Time.UTC().Format(time.RFC3339),
+	}
+	if s := m.Streamer; s != nil && m.Kind == changes.WentLive {
+		for _, a := range s.Accounts {
+			embed.Fields = append(embed.Fields, discordField{Name: string(a.Platform), Value: a.URL, Inline: true})
+		}
+		if s.Lang != "" {
+			embed.Fields = append(embed.Fields, discordField{Name: "Language", Value: s.Lang, Inline: true})
+		}
+	}
+	return postJSON(ctx, d.Client, "discord webhook", d.WebhookURL, nil, discordMessage{Username: m.List, Embeds: []discordEmbed{embed}})
+}
+
+// postJSON sends v as json to url and fails on any non-2xx status. what names the service in errors.
+func postJSON(ctx context.Context, client *http.Client, what, url string, header http.Header, v any) error {
+	return sendJSON(ctx, client, "POST", what, url, header, v)
+}
+
+func sendJSON(ctx context.Context, client *http.Client, method, what, url string, header http.Header, v any) error {
+	// Slack's <url|text> links don't need escaping for html
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, method, url, &body)
+	if err != nil {
+		return err
+	}
+	for k, vs := range header {
+		request.Header[k] = vs
+	}
+	request.Header.Set("Content-Type", "application/json")
+	return do(client, what, request)
+}
+
+func do(client *http.Client, what string, request *http.Request) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	r, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if r.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(r.Body, 512))
+		return fmt.Errorf("%s: %s: %s", what, r.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
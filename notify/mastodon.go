@@ -0,0 +1,45 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// mastodonStatusLimit is the default most characters a Mastodon server accepts in a status.
+const mastodonStatusLimit = 500
+
+// Mastodon posts statuses to a Mastodon account.
+// See https://docs.joinmastodon.org/methods/statuses/#create.
+type Mastodon struct {
+	Server      string       // The server's url, e.g. https://infosec.exchange
+	AccessToken string       // An access token with the write:statuses scope
+	Visibility  string       // public, unlisted, private or direct; the account's default if empty
+	Client      *http.Client // The client to post with, http.DefaultClient if nil
+}
+
+// Send posts m as a status, cut to fit the server's limit. An Idempotency-Key derived from the
+// message stops the server posting a retried message twice.
+func (md Mastodon) Send(ctx context.Context, m Message) error {
+	status := strings.TrimSpace(m.Text)
+	if m.Kind == KindDigest {
+		status = m.Title + "\n\n" + status
+	}
+	if runes := []rune(status); len(runes) > mastodonStatusLimit {
+		status = string(runes[:mastodonStatusLimit-1]) + "…"
+	}
+
+	form := url.Values{"status": {status}}
+	if md.Visibility != "" {
+		form.Set("visibility", md.Visibility)
+	}
+	request, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(md.Server, "/")+"/api/v1/statuses", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "Bearer "+md.AccessToken)
+	request.Header.Set("Idempotency-Key", messageID(m))
+	return do(md.Client, "mastodon", request)
+}
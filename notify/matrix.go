@@ -0,0 +1,30 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Matrix sends notices to a Matrix room through the client-server API.
+// See https://spec.matrix.org/latest/client-server-api/#put_matrixclientv3roomsroomidsendeventtypetxnid.
+type Matrix struct {
+	Homeserver  string       // The homeserver's url, e.g. https://matrix.org
+	AccessToken string       // An access token for a user in the room
+	RoomID      string       // The room to send to, e.g. !abc:matrix.org
+	Client      *http.Client // The client to send with, http.DefaultClient if nil
+}
+
+// Send sends m as an m.notice. The transaction ID is derived from the message, so the
+// homeserver drops a message that's retried after it was already delivered.
+func (mx Matrix) Send(ctx context.Context, m Message) error {
+	body := strings.TrimSpace(m.Text)
+	if m.Kind == KindDigest {
+		body = m.Title + "\n\n" + body
+	}
+	endpoint := strings.TrimSuffix(mx.Homeserver, "/") + "/_matrix/client/v3/rooms/" + url.PathEscape(mx.RoomID) +
+		"/send/m.room.message/" + messageID(m)
+	header := http.Header{"Authorization": {"Bearer " + mx.AccessToken}}
+	return sendJSON(ctx, mx.Client, "PUT", "matrix", endpoint, header, map[string]string{"msgtype": "m.notice", "body": body})
+}
@@ -0,0 +1,279 @@
+/*
+Package notify announces streamers going live, and a daily digest of list changes, to chat services.
+
+Every service is a Notifier wrapped in a Channel, which picks the kinds of change it's sent and the
+templates its messages are worded with. What each channel has been sent is kept in a State, so a
+restart doesn't announce anything twice and a failed send is retried on the next run.
+*/
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/infosecstreams/secinfo/changes"
+	"github.com/infosecstreams/secinfo/streamers"
+)
+
+// DigestInterval is how long to collect changes for before sending a digest.
+const DigestInterval = 24 * time.Hour
+
+// KindDigest is the kind of the periodic digest message, for Channel.Templates.
+const KindDigest changes.Kind = "digest"
+
+// Notifier sends messages to one chat service.
+type Notifier interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// Templater is implemented by Notifiers whose service has its own markup, to replace DefaultTemplates.
+type Templater interface {
+	Templates() map[changes.Kind]string
+}
+
+// Message is a single notification, already rendered from its template.
+type Message struct {
+	Kind     changes.Kind        // The kind of change, or KindDigest
+	Title    string              // A short title, e.g. "alice is live!"
+	Text     string              // The rendered template
+	URL      string              // Where the message links to
+	Time     time.Time           // When the change was observed
+	Streamer *streamers.Streamer // The streamer the message is about, nil for a digest
+	List     string              // The title of the list the message is about, empty if it has none
+}
+
+// Item is a single change, as seen by the message templates.
+type Item struct {
+	Kind     changes.Kind `json:"kind"`
+	Name     string       `json:"name"`
+	Hours    float32      `json:"hours"`
+	Lang     string       `json:"lang,omitempty"`
+	URL      string       `json:"url"`
+	Observed time.Time    `json:"observed"`
+}
+
+// Digest is the list changes collected since the last digest, as seen by the digest template.
+type Digest struct {
+	Since    time.Time // When the previous digest was sent, zero for the first one
+	Until    time.Time // When this digest was sent
+	Added    []Item    // Streamers added to the list
+	Removed  []Item    // Streamers removed from the list
+	Promoted []Item    // Streamers that moved back to the active list
+	Demoted  []Item    // Streamers that moved to the inactive list
+	Live     []Item    // Streamers that went live
+}
+
+// DefaultTemplates word the messages of Notifiers that aren't a Templater.
+// Event templates get an Item, the KindDigest template gets a Digest.
+var DefaultTemplates = map[changes.Kind]string{
+	changes.WentLive: `{{.Name}} is live{{with .Lang}} ({{.}}){{end}}! {{.URL}}`,
+	changes.Added:    `{{.Name}} was added to the list: {{.URL}}`,
+	changes.Removed:  `{{.Name}} was removed from the list.`,
+	changes.Promoted: `{{.Name}} is active again with {{printf "%.1f" .Hours}} hours in the last {{windowDays}} days: {{.URL}}`,
+	changes.Demoted:  `{{.Name}} moved to the inactive list.`,
+	KindDigest: `{{with .Added}}Added:
+{{range .}}- {{.Name}} {{.URL}}
+{{end}}{{end}}{{with .Promoted}}Back to active:
+{{range .}}- {{.Name}} ({{printf "%.1f" .Hours}}h) {{.URL}}
+{{end}}{{end}}{{with .Demoted}}Moved to inactive:
+{{range .}}- {{.Name}}
+{{end}}{{end}}{{with .Removed}}Removed:
+{{range .}}- {{.Name}}
+{{end}}{{end}}{{with .Live}}Went live:
+{{range .}}- {{.Name}} {{.URL}}
+{{end}}{{end}}`,
+}
+
+var titles = map[changes.Kind]string{
+	changes.WentLive: "%s is live!",
+	changes.Added:    "%s was added",
+	changes.Removed:  "%s was removed",
+	changes.Promoted: "%s is active again",
+	changes.Demoted:  "%s is inactive",
+}
+
+// Channel is a Notifier with the kinds of change it's sent and the templates its messages use.
+type Channel struct {
+	Name       string                  // Unique name, the channel's State is stored under it
+	Notifier   Notifier                // Where messages are sent
+	Events     []changes.Kind          // Kinds sent as soon as they happen, WentLive if nil
+	Digest     []changes.Kind          // Kinds collected into the daily digest, Added, Promoted and Demoted if nil
+	Templates  map[changes.Kind]string // Templates by kind, replacing the Notifier's or DefaultTemplates
+	WindowDays int                     // Days of activity Hours covers, for the windowDays template func; streamers.WindowDays if zero
+	Title      string                  // The list's title, the digest is named after it
+	SiteURL    string                  // The list's site, the digest links to it
+}
+
+func (c Channel) events() []changes.Kind {
+	if c.Events == nil {
+		return []changes.Kind{changes.WentLive}
+	}
+	return c.Events
+}
+
+func (c Channel) digest() []changes.Kind {
+	if c.Digest == nil {
+		return []changes.Kind{changes.Added, changes.Promoted, changes.Demoted}
+	}
+	return c.Digest
+}
+
+// template returns the channel's template for kind: its own, the Notifier's, or the default.
+func (c Channel) template(kind changes.Kind) (*template.Template, error) {
+	text, ok := c.Templates[kind]
+	if !ok {
+		if t, isTemplater := c.Notifier.(Templater); isTemplater {
+			text, ok = t.Templates()[kind]
+		}
+	}
+	if !ok {
+		text = DefaultTemplates[kind]
+	}
+	funcs := template.FuncMap{"windowDays": func() int {
+		if c.WindowDays == 0 {
+			return streamers.WindowDays
+		}
+		return c.WindowDays
+	}}
+	tmpl, err := template.New(string(kind)).Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("channel %s: %s template: %w", c.Name, kind, err)
+	}
+	return tmpl, nil
+}
+
+// Validate checks that the channel's kinds are known and its templates parse.
+func (c Channel) Validate() error {
+	var errs []error
+	if c.Name == "" {
+		errs = append(errs, errors.New("channel has no name"))
+	}
+	for _, kind := range append(append([]changes.Kind{}, c.events()...), c.digest()...) {
+		if _, ok := DefaultTemplates[kind]; !ok || kind == KindDigest {
+			errs = append(errs, fmt.Errorf("channel %s: unknown event %q", c.Name, kind))
+		}
+	}
+	for kind := range c.Templates {
+		if _, ok := DefaultTemplates[kind]; !ok {
+			errs = append(errs, fmt.Errorf("channel %s: template for unknown event %q", c.Name, kind))
+			continue
+		}
+		if _, err := c.template(kind); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c Channel) render(kind changes.Kind, data any) (string, error) {
+	tmpl, err := c.template(kind)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("channel %s: %s template: %w", c.Name, kind, err)
+	}
+	return buf.String(), nil
+}
+
+// Process sends every channel the events it wants right away, and queues the ones it wants digested.
+// A channel's digest is sent once DigestInterval has passed since its last one. st is updated with
+// everything that was sent, so re-running with the same events doesn't send them again.
+func Process(ctx context.Context, channels []Channel, st *State, cur changes.Run, events []changes.Event, now time.Time) error {
+	var errs []error
+	for _, c := range channels {
+		if err := c.process(ctx, st.channel(c.Name), cur, events, now); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c Channel) process(ctx context.Context, st *ChannelState, cur changes.Run, events []changes.Event, now time.Time) error {
+	st.sync(cur)
+
+	var errs []error
+	for _, e := range events {
+		item := newItem(e, now)
+		if hasKind(c.events(), e.Kind) {
+			if st.sent(e, now) {
+				continue
+			}
+			text, err := c.render(e.Kind, item)
+			if err != nil {
+				return err
+			}
+			s := e.Streamer
+			m := Message{Kind: e.Kind, Title: fmt.Sprintf(titles[e.Kind], s.Name), Text: text, URL: item.URL, Time: now.UTC(), Streamer: &s, List: c.Title}
+			if err := c.Notifier.Send(ctx, m); err != nil {
+				errs = append(errs, fmt.Errorf("sending %s %s: %w", e.Kind, s.Name, err))
+				continue
+			}
+			st.markSent(e, now)
+		}
+		if hasKind(c.digest(), e.Kind) {
+			st.queue(item)
+		}
+	}
+
+	if len(st.Pending) > 0 && now.Sub(st.LastDigest) >= DigestInterval {
+		digest := Digest{Since: st.LastDigest, Until: now.UTC()}
+		for _, item := range st.Pending {
+			switch item.Kind {
+			case changes.Added:
+				digest.Added = append(digest.Added, item)
+			case changes.Removed:
+				digest.Removed = append(digest.Removed, item)
+			case changes.Promoted:
+				digest.Promoted = append(digest.Promoted, item)
+			case changes.Demoted:
+				digest.Demoted = append(digest.Demoted, item)
+			case changes.WentLive:
+				digest.Live = append(digest.Live, item)
+			}
+		}
+		text, err := c.render(KindDigest, digest)
+		if err != nil {
+			return err
+		}
+		m := Message{Kind: KindDigest, Title: strings.TrimSpace(c.Title + " daily digest"), Text: text, URL: c.SiteURL, Time: now.UTC(), List: c.Title}
+		if err := c.Notifier.Send(ctx, m); err != nil {
+			errs = append(errs, fmt.Errorf("sending digest: %w", err))
+		} else {
+			st.Pending = nil
+			st.LastDigest = now.UTC()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// messageID is a stable ID for m, for services that drop retried messages with a known ID.
+func messageID(m Message) string {
+	sum := sha256.Sum256([]byte(string(m.Kind) + "\x00" + m.Title + "\x00" + m.Time.UTC().Format(time.RFC3339)))
+	return hex.EncodeToString(sum[:16])
+}
+
+func newItem(e changes.Event, now time.Time) Item {
+	item := Item{Kind: e.Kind, Name: e.Streamer.Name, Hours: e.Streamer.ThirtyDayStats, Lang: e.Streamer.Lang, Observed: now.UTC()}
+	if len(e.Streamer.Accounts) > 0 {
+		item.URL = e.Streamer.Accounts[0].URL
+	}
+	return item
+}
+
+func hasKind(kinds []changes.Kind, kind changes.Kind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,328 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/infosecstreams/secinfo/changes"
+	"github.com/infosecstreams/secinfo/notify"
+	"github.com/infosecstreams/secinfo/streamers"
+	"github.com/spf13/afero"
+)
+
+// sink is an httptest webhook that records every request body.
+type sink struct {
+	*httptest.Server
+	mu     sync.Mutex
+	bodies []string
+	status int
+}
+
+func newSink(t *testing.T) *sink {
+	t.Helper()
+
+	s := &sink{status: http.StatusNoContent}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.status/100 == 2 {
+			s.bodies = append(s.bodies, string(body))
+		}
+		w.WriteHeader(s.status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *sink) take() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bodies := s.bodies
+	s.bodies = nil
+	return bodies
+}
+
+func streamer(name string, online bool) streamers.Streamer {
+	s := streamers.Streamer{Name: name, ThirtyDayStats: 5, Online: online, Lang: "EN"}
+	s.SetAccount(streamers.Account{Platform: streamers.Twitch, Handle: name})
+	return s
+}
+
+func TestProcessLiveDedupe(t *testing.T) {
+	hook := newSink(t)
+	d := []notify.Channel{{Name: "discord", Notifier: notify.Discord{WebhookURL: hook.URL}, Title: "CTF Streams"}}
+	st, _ := notify.LoadState(afero.NewMemMapFs(), "notify_state.json")
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	alice := streamer("alice", true)
+	cur := changes.Run{Active: []streamers.Streamer{alice}}
+	events := []changes.Event{{Kind: changes.WentLive, Streamer: alice}}
+
+	if err := notify.Process(context.Background(), d, st, cur, events, now); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	bodies := hook.take()
+	if len(bodies) != 1 {
+		t.Fatalf("Got: %d posts, Wanted: 1", len(bodies))
+	}
+	var msg struct {
+		Username string `json:"username"`
+		Embeds   []struct {
+			Title string `json:"title"`
+			URL   string `json:"url"`
+		} `json:"embeds"`
+	}
+	if err := json.Unmarshal([]byte(bodies[0]), &msg); err != nil {
+		t.Fatalf("not json: %v", err)
+	}
+	if msg.Embeds[0].Title != "alice is live!" || msg.Embeds[0].URL != "https://www.twitch.tv/alice" || msg.Username != "CTF Streams" {
+		t.Fatalf("Got: %+v, Wanted alice's live announcement from CTF Streams", msg)
+	}
+
+	// A restart replays the same events, nothing is announced again
+	if err := notify.Process(context.Background(), d, st, cur, events, now.Add(time.Minute)); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if bodies := hook.take(); len(bodies) != 0 {
+		t.Fatalf("re-announced: %q", bodies)
+	}
+
+	// Once alice is offline, her next stream is announced
+	notify.Process(context.Background(), d, st, changes.Run{Active: []streamers.Streamer{streamer("alice", false)}}, nil, now.Add(time.Hour))
+	notify.Process(context.Background(), d, st, cur, events, now.Add(2*time.Hour))
+	if bodies := hook.take(); len(bodies) != 1 {
+		t.Fatalf("Got: %d posts, Wanted: 1", len(bodies))
+	}
+}
+
+func TestProcessDigest(t *testing.T) {
+	hook := newSink(t)
+	d := []notify.Channel{{Name: "discord", Notifier: notify.Discord{WebhookURL: hook.URL}}}
+	fs := afero.NewMemMapFs()
+	st, _ := notify.LoadState(fs, "notify_state.json")
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	events := []changes.Event{
+		{Kind: changes.Added, Streamer: streamer("zed", false)},
+		{Kind: changes.Promoted, Streamer: streamer("erin", false)},
+		{Kind: changes.Demoted, Streamer: streamer("carol", false)},
+		{Kind: changes.Removed, Streamer: streamer("dave", false)},
+	}
+	if err := notify.Process(context.Background(), d, st, changes.Run{}, events, now); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	bodies := hook.take()
+	if len(bodies) != 1 {
+		t.Fatalf("Got: %d posts, Wanted: 1", len(bodies))
+	}
+	for _, want := range []string{`**Added**\n- [zed](https://www.twitch.tv/zed)`, `**Back to active**\n- [erin](https://www.twitch.tv/erin) (5.0h)`, `**Moved to inactive**\n- [carol](https://www.twitch.tv/carol)`} {
+		if !strings.Contains(bodies[0], want) {
+			t.Errorf("digest is missing %s:\n%s", want, bodies[0])
+		}
+	}
+	if strings.Contains(bodies[0], "dave") {
+		t.Errorf("removals aren't part of the digest:\n%s", bodies[0])
+	}
+
+	// Changes within a day wait for the next digest, even across restarts
+	later := []changes.Event{{Kind: changes.Added, Streamer: streamer("yan", false)}}
+	notify.Process(context.Background(), d, st, changes.Run{}, later, now.Add(time.Hour))
+	data, _ := st.JSON()
+	afero.WriteFile(fs, "notify_state.json", data, 0644)
+	st, err := notify.LoadState(fs, "notify_state.json")
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	notify.Process(context.Background(), d, st, changes.Run{}, later, now.Add(2*time.Hour))
+	if bodies := hook.take(); len(bodies) != 0 {
+		t.Fatalf("digest sent too early: %q", bodies)
+	}
+
+	notify.Process(context.Background(), d, st, changes.Run{}, nil, now.Add(25*time.Hour))
+	bodies = hook.take()
+	if len(bodies) != 1 || strings.Count(bodies[0], "[yan]") != 1 {
+		t.Fatalf("Got: %q, Wanted: one digest with yan once", bodies)
+	}
+}
+
+func TestProcessFailureRetries(t *testing.T) {
+	hook := newSink(t)
+	hook.status = http.StatusInternalServerError
+	d := []notify.Channel{{Name: "discord", Notifier: notify.Discord{WebhookURL: hook.URL}}}
+	st, _ := notify.LoadState(afero.NewMemMapFs(), "notify_state.json")
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	alice := streamer("alice", true)
+	cur := changes.Run{Active: []streamers.Streamer{alice}}
+	events := []changes.Event{{Kind: changes.WentLive, Streamer: alice}, {Kind: changes.Added, Streamer: alice}}
+	if err := notify.Process(context.Background(), d, st, cur, events, now); err == nil {
+		t.Fatalf("Process should fail")
+	}
+	if c := st.Channels["discord"]; len(c.Live) != 0 || len(c.Pending) != 1 {
+		t.Fatalf("failed sends shouldn't be recorded: %+v", st)
+	}
+
+	hook.status = http.StatusNoContent
+	if err := notify.Process(context.Background(), d, st, cur, events, now.Add(time.Minute)); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if bodies := hook.take(); len(bodies) != 2 {
+		t.Fatalf("Got: %d posts, Wanted: 2", len(bodies))
+	}
+}
+
+func TestLoadStateFail(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "notify_state.json", []byte("{"), 0644)
+	if _, err := notify.LoadState(fs, "notify_state.json"); err == nil {
+		t.Fatalf("LoadState should fail on broken json")
+	}
+}
+
+// request is a request recorded by recorder.
+type request struct {
+	method, path, auth, idempotency, contentType, body string
+}
+
+// recorder is an httptest server standing in for Slack, Matrix and Mastodon, recording every request.
+func recorder(t *testing.T) (*httptest.Server, *[]request) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, request{r.Method, r.URL.EscapedPath(), r.Header.Get("Authorization"), r.Header.Get("Idempotency-Key"), r.Header.Get("Content-Type"), strings.TrimSpace(string(body))})
+		mu.Unlock()
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestBackends(t *testing.T) {
+	server, requests := recorder(t)
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	alice := streamer("alice", true)
+	events := []changes.Event{
+		{Kind: changes.WentLive, Streamer: alice},
+		{Kind: changes.Added, Streamer: streamer("zed", false)},
+	}
+	channels := []notify.Channel{
+		{Name: "slack", Notifier: notify.Slack{WebhookURL: server.URL + "/slack"}, Title: "InfoSec Streams"},
+		{Name: "matrix", Notifier: notify.Matrix{Homeserver: server.URL, AccessToken: "mx", RoomID: "!room:example.com"}, Title: "InfoSec Streams"},
+		{Name: "mastodon", Notifier: notify.Mastodon{Server: server.URL, AccessToken: "md", Visibility: "unlisted"}, Title: "CTF Streams"},
+	}
+	st, _ := notify.LoadState(afero.NewMemMapFs(), "notify_state.json")
+	if err := notify.Process(context.Background(), channels, st, changes.Run{Active: []streamers.Streamer{alice}}, events, now); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+
+	// Each channel gets the live announcement and then its digest
+	if len(*requests) != 6 {
+		t.Fatalf("Got: %d requests, Wanted: 6: %+v", len(*requests), *requests)
+	}
+	slackLive, slackDigest := (*requests)[0], (*requests)[1]
+	if slackLive.path != "/slack" || slackLive.body != `{"text":"<https://www.twitch.tv/alice|alice> is live (EN)!"}` {
+		t.Errorf("slack live Got: %+v", slackLive)
+	}
+	if !strings.Contains(slackDigest.body, `*InfoSec Streams daily digest*\n*Added*\n• <https://www.twitch.tv/zed|zed>`) {
+		t.Errorf("slack digest Got: %+v", slackDigest)
+	}
+
+	matrixLive := (*requests)[2]
+	if matrixLive.method != "PUT" || !strings.HasPrefix(matrixLive.path, "/_matrix/client/v3/rooms/%21room:example.com/send/m.room.message/") || matrixLive.auth != "Bearer mx" {
+		t.Errorf("matrix live Got: %+v", matrixLive)
+	}
+	if matrixLive.body != `{"body":"alice is live (EN)! https://www.twitch.tv/alice","msgtype":"m.notice"}` {
+		t.Errorf("matrix live Got: %s", matrixLive.body)
+	}
+	if (*requests)[3].path == matrixLive.path {
+		t.Errorf("matrix transaction IDs should differ per message")
+	}
+
+	mastodonLive, mastodonDigest := (*requests)[4], (*requests)[5]
+	if mastodonLive.path != "/api/v1/statuses" || mastodonLive.auth != "Bearer md" || mastodonLive.idempotency == "" || mastodonLive.contentType != "application/x-www-form-urlencoded" {
+		t.Errorf("mastodon live Got: %+v", mastodonLive)
+	}
+	if mastodonLive.body != "status=alice+is+live+%28EN%29%21+https%3A%2F%2Fwww.twitch.tv%2Falice&visibility=unlisted" {
+		t.Errorf("mastodon live Got: %s", mastodonLive.body)
+	}
+	if !strings.HasPrefix(mastodonDigest.body, "status=CTF+Streams+daily+digest%0A%0AAdded%3A%0A-+zed+https") {
+		t.Errorf("mastodon digest Got: %s", mastodonDigest.body)
+	}
+}
+
+func TestChannelEventsAndTemplates(t *testing.T) {
+	server, requests := recorder(t)
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	channels := []notify.Channel{{
+		Name:     "slack",
+		Notifier: notify.Slack{WebhookURL: server.URL},
+		Events:   []changes.Kind{changes.Added, changes.Demoted},
+		Digest:   []changes.Kind{},
+		Templates: map[changes.Kind]string{
+			changes.Added: `new: {{.Name}}`,
+		},
+	}}
+	events := []changes.Event{
+		{Kind: changes.WentLive, Streamer: streamer("alice", true)},
+		{Kind: changes.Added, Streamer: streamer("zed", false)},
+		{Kind: changes.Demoted, Streamer: streamer("carol", false)},
+	}
+	st, _ := notify.LoadState(afero.NewMemMapFs(), "notify_state.json")
+	if err := notify.Process(context.Background(), channels, st, changes.Run{}, events, now); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	// Replaying the events doesn't send them twice
+	notify.Process(context.Background(), channels, st, changes.Run{}, events, now.Add(time.Hour))
+
+	var bodies []string
+	for _, r := range *requests {
+		bodies = append(bodies, r.body)
+	}
+	want := []string{`{"text":"new: zed"}`, `{"text":"<https://www.twitch.tv/carol|carol> moved to the inactive list."}`}
+	if strings.Join(bodies, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Got: %q, Wanted: %q", bodies, want)
+	}
+}
+
+func TestChannelValidate(t *testing.T) {
+	good := notify.Channel{Name: "slack", Notifier: notify.Slack{}, Templates: map[changes.Kind]string{notify.KindDigest: "{{range .Added}}{{.Name}}{{end}}"}}
+	if err := good.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	for _, bad := range []notify.Channel{
+		{Notifier: notify.Slack{}},
+		{Name: "a", Notifier: notify.Slack{}, Events: []changes.Kind{"party"}},
+		{Name: "a", Notifier: notify.Slack{}, Digest: []changes.Kind{notify.KindDigest}},
+		{Name: "a", Notifier: notify.Slack{}, Templates: map[changes.Kind]string{changes.Added: "{{.Name"}},
+		{Name: "a", Notifier: notify.Slack{}, Templates: map[changes.Kind]string{"party": "hi"}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate should fail for %+v", bad)
+		}
+	}
+}
+
+func TestLoadLegacyState(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "notify_state.json", []byte(`{"live":{"alice":"2026-10-19T12:00:00Z"},"pending":[{"kind":"added","name":"zed"}],"last_digest":"2026-10-18T12:00:00Z"}`), 0644)
+	st, err := notify.LoadState(fs, "notify_state.json")
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	c := st.Channels["discord"]
+	if c == nil || len(c.Live) != 1 || len(c.Pending) != 1 || c.LastDigest.IsZero() {
+		t.Fatalf("legacy state should become the discord channel's: %+v", st.Channels)
+	}
+}
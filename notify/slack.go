@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/infosecstreams/secinfo/changes"
+)
+
+// Slack posts messages to a Slack incoming webhook.
+// See https://api.slack.com/messaging/webhooks.
+type Slack struct {
+	WebhookURL string       // The webhook's url
+	Client     *http.Client // The client to post with, http.DefaultClient if nil
+}
+
+// Templates word Slack messages with mrkdwn links.
+func (Slack) Templates() map[changes.Kind]string {
+	return map[changes.Kind]string{
+		changes.WentLive: `<{{.URL}}|{{.Name}}> is live{{with .Lang}} ({{.}}){{end}}!`,
+		changes.Added:    `<{{.URL}}|{{.Name}}> was added to the list.`,
+		changes.Promoted: `<{{.URL}}|{{.Name}}> is active again with {{printf "%.1f" .Hours}} hours in the last {{windowDays}} days.`,
+		changes.Demoted:  `<{{.URL}}|{{.Name}}> moved to the inactive list.`,
+		KindDigest: `{{with .Added}}*Added*
+{{range .}}• <{{.URL}}|{{.Name}}>
+{{end}}{{end}}{{with .Promoted}}*Back to active*
+{{range .}}• <{{.URL}}|{{.Name}}> ({{printf "%.1f" .Hours}}h)
+{{end}}{{end}}{{with .Demoted}}*Moved to inactive*
+{{range .}}• <{{.URL}}|{{.Name}}>
+{{end}}{{end}}{{with .Removed}}*Removed*
+{{range .}}• {{.Name}}
+{{end}}{{end}}{{with .Live}}*Went live*
+{{range .}}• <{{.URL}}|{{.Name}}>
+{{end}}{{end}}`,
+	}
+}
+
+// Send posts m, with digests getting their title in bold on top.
+func (s Slack) Send(ctx context.Context, m Message) error {
+	text := strings.TrimSpace(m.Text)
+	if m.Kind == KindDigest {
+		text = "*" + m.Title + "*\n" + text
+	}
+	return postJSON(ctx, s.Client, "slack webhook", s.WebhookURL, nil, map[string]string{"text": text})
+}
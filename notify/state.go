@@ -0,0 +1,136 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/infosecstreams/secinfo/changes"
+	"github.com/spf13/afero"
+)
+
+// SentRetention is how long a sent event is remembered, to not send it again if it's replayed.
+const SentRetention = 7 * 24 * time.Hour
+
+// State is what's already been sent to each channel, persisted between runs.
+type State struct {
+	Channels map[string]*ChannelState `json:"channels"`
+}
+
+// ChannelState is what's already been sent to one channel.
+type ChannelState struct {
+	Live       map[string]time.Time `json:"live"`        // Streamers announced live, by lower-cased name, until they go offline
+	Sent       map[string]time.Time `json:"sent"`        // Other events sent right away, by kind/name, for SentRetention
+	Pending    []Item               `json:"pending"`     // Changes waiting for the next digest
+	LastDigest time.Time            `json:"last_digest"` // When the last digest was sent
+}
+
+// LoadState reads the state file at path. A missing file is an empty State.
+// Files written when Discord was the only channel are loaded as the "discord" channel's state.
+func LoadState(fileSystem afero.Fs, path string) (*State, error) {
+	st := &State{Channels: map[string]*ChannelState{}}
+	data, err := afero.ReadFile(fileSystem, path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		State
+		ChannelState
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if file.Channels != nil {
+		st.Channels = file.Channels
+	}
+	if legacy := file.ChannelState; len(legacy.Live)+len(legacy.Pending) > 0 || !legacy.LastDigest.IsZero() {
+		if _, ok := st.Channels["discord"]; !ok {
+			st.Channels["discord"] = &legacy
+		}
+	}
+	return st, nil
+}
+
+// JSON returns the state file content.
+func (st *State) JSON() ([]byte, error) {
+	return json.MarshalIndent(st, "", "  ")
+}
+
+func (st *State) channel(name string) *ChannelState {
+	c, ok := st.Channels[name]
+	if !ok {
+		c = &ChannelState{}
+		st.Channels[name] = c
+	}
+	if c.Live == nil {
+		c.Live = map[string]time.Time{}
+	}
+	if c.Sent == nil {
+		c.Sent = map[string]time.Time{}
+	}
+	return c
+}
+
+// sync forgets live announcements for streamers that are no longer online, so their next stream is announced.
+func (st *ChannelState) sync(cur changes.Run) {
+	online := map[string]bool{}
+	for _, s := range cur.Active {
+		if s.Online {
+			online[strings.ToLower(s.Name)] = true
+		}
+	}
+	for name := range st.Live {
+		if !online[name] {
+			delete(st.Live, name)
+		}
+	}
+}
+
+// sent reports whether e was already sent: for WentLive while the streamer is still live,
+// for anything else within SentRetention.
+func (st *ChannelState) sent(e changes.Event, now time.Time) bool {
+	if e.Kind == changes.WentLive {
+		_, ok := st.Live[strings.ToLower(e.Streamer.Name)]
+		return ok
+	}
+	at, ok := st.Sent[sentKey(e)]
+	return ok && now.Sub(at) < SentRetention
+}
+
+func (st *ChannelState) markSent(e changes.Event, now time.Time) {
+	if e.Kind == changes.WentLive {
+		st.Live[strings.ToLower(e.Streamer.Name)] = now.UTC()
+		return
+	}
+	st.Sent[sentKey(e)] = now.UTC()
+	for key, at := range st.Sent {
+		if now.Sub(at) >= SentRetention {
+			delete(st.Sent, key)
+		}
+	}
+}
+
+func sentKey(e changes.Event) string {
+	return string(e.Kind) + "/" + strings.ToLower(e.Streamer.Name)
+}
+
+// queue adds item to the pending digest unless the same change is already waiting.
+func (st *ChannelState) queue(item Item) {
+	for _, p := range st.Pending {
+		if p.Kind == item.Kind && strings.EqualFold(p.Name, item.Name) {
+			return
+		}
+	}
+	st.Pending = append(st.Pending, item)
+	sort.SliceStable(st.Pending, func(i, j int) bool {
+		return st.Pending[i].Observed.Before(st.Pending[j].Observed)
+	})
+}
@@ -0,0 +1,116 @@
+/* Package output stages generated files and moves them into place together. */
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+)
+
+// Batch collects generated files so they're only written once every one of them rendered.
+type Batch struct {
+	files []file
+}
+
+type file struct {
+	path string
+	data []byte
+}
+
+// Add queues data to be written to path. Adding the same path again replaces the queued data.
+func (b *Batch) Add(path string, data []byte) {
+	for i := range b.files {
+		if b.files[i].path == path {
+			b.files[i].data = data
+			return
+		}
+	}
+	b.files = append(b.files, file{path: path, data: data})
+}
+
+// AddJSON queues v, marshalled to json, to be written to path.
+func (b *Batch) AddJSON(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshalling %s: %w", path, err)
+	}
+	b.Add(path, data)
+	return nil
+}
+
+// Paths returns the queued paths in the order they were added.
+func (b *Batch) Paths() []string {
+	paths := make([]string, 0, len(b.files))
+	for _, f := range b.files {
+		paths = append(paths, f.path)
+	}
+	return paths
+}
+
+// Each calls fn with every queued file in the order they were added.
+func (b *Batch) Each(fn func(path string, data []byte)) {
+	for _, f := range b.files {
+		fn(f.path, f.data)
+	}
+}
+
+// Commit writes every queued file to a temp file next to its destination, then renames them all into place.
+// If any temp file can't be written, the temp files are removed and no destination is touched.
+func (b *Batch) Commit(fileSystem afero.Fs) error {
+	temps := make([]string, 0, len(b.files))
+	cleanup := func() {
+		for _, tmp := range temps {
+			fileSystem.Remove(tmp)
+		}
+	}
+
+	for _, f := range b.files {
+		tmp, err := writeTemp(fileSystem, f)
+		if tmp != "" {
+			temps = append(temps, tmp)
+		}
+		if err != nil {
+			cleanup()
+			return fmt.Errorf("writing %s: %w", f.path, err)
+		}
+	}
+
+	var errs []error
+	for i, f := range b.files {
+		if err := fileSystem.Rename(temps[i], f.path); err != nil {
+			fileSystem.Remove(temps[i])
+			errs = append(errs, fmt.Errorf("replacing %s: %w", f.path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// writeTemp writes f to a new temp file in the same directory, so the rename stays on one filesystem.
+func writeTemp(fileSystem afero.Fs, f file) (string, error) {
+	dir, base := filepath.Split(f.path)
+	if dir == "" {
+		dir = "."
+	}
+	if err := fileSystem.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	tmp, err := afero.TempFile(fileSystem, dir, "."+base+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(f.data); err != nil {
+		tmp.Close()
+		return tmp.Name(), err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return tmp.Name(), err
+	}
+	if err := tmp.Close(); err != nil {
+		return tmp.Name(), err
+	}
+	return tmp.Name(), fileSystem.Chmod(tmp.Name(), 0644)
+}
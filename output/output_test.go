@@ -0,0 +1,88 @@
+package output_test
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/infosecstreams/secinfo/output"
+	"github.com/spf13/afero"
+)
+
+// failingFs fails to create temp files for destinations containing "bad".
+type failingFs struct {
+	afero.Fs
+}
+
+func (f failingFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if strings.Contains(name, "bad") {
+		return nil, errors.New("disk full")
+	}
+	return f.Fs.OpenFile(name, flag, perm)
+}
+
+func TestCommit(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "index.md", []byte("old"), 0644)
+
+	var b output.Batch
+	b.Add("index.md", []byte("first"))
+	b.Add("index.md", []byte("new"))
+	if err := b.AddJSON("api/v1/list.json", map[string]int{"count": 1}); err != nil {
+		t.Fatalf("AddJSON failed: %v", err)
+	}
+	if err := b.Commit(fs); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	for path, want := range map[string]string{"index.md": "new", "api/v1/list.json": `{"count":1}`} {
+		got, err := afero.ReadFile(fs, path)
+		if err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: Got: %q, Wanted: %q", path, got, want)
+		}
+	}
+	assertNoTemps(t, fs)
+}
+
+func TestCommitFailLeavesFiles(t *testing.T) {
+	fs := failingFs{afero.NewMemMapFs()}
+	afero.WriteFile(fs, "index.md", []byte("old"), 0644)
+
+	var b output.Batch
+	b.Add("index.md", []byte("new"))
+	b.Add("bad.md", []byte("new"))
+	if err := b.Commit(fs); err == nil {
+		t.Fatalf("Commit should fail")
+	}
+
+	got, _ := afero.ReadFile(fs, "index.md")
+	if string(got) != "old" {
+		t.Fatalf("index.md was modified: %q", got)
+	}
+	assertNoTemps(t, fs)
+}
+
+func TestAddJSONFail(t *testing.T) {
+	var b output.Batch
+	if err := b.AddJSON("bad.json", make(chan int)); err == nil {
+		t.Fatalf("AddJSON should fail")
+	}
+	if len(b.Paths()) != 0 {
+		t.Fatalf("nothing should be queued: %v", b.Paths())
+	}
+}
+
+func assertNoTemps(t *testing.T, fs afero.Fs) {
+	t.Helper()
+
+	afero.Walk(fs, ".", func(path string, info os.FileInfo, err error) error {
+		if strings.Contains(path, ".tmp-") {
+			t.Errorf("temp file left behind: %s", path)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,513 @@
+/*
+Package pipeline updates streamer lists: it reads the csv files, fetches every streamer's stats, classifies them as
+active or inactive and renders every configured output from the result.
+
+Run does a whole update on any file system, so it can run in memory in tests or inside other programs.
+Every output is rendered before anything is written, so a failure leaves the existing files untouched.
+*/
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/infosecstreams/secinfo/api"
+	"github.com/infosecstreams/secinfo/calendar"
+	"github.com/infosecstreams/secinfo/changes"
+	"github.com/infosecstreams/secinfo/chart"
+	"github.com/infosecstreams/secinfo/config"
+	"github.com/infosecstreams/secinfo/export"
+	"github.com/infosecstreams/secinfo/feed"
+	"github.com/infosecstreams/secinfo/history"
+	"github.com/infosecstreams/secinfo/output"
+	"github.com/infosecstreams/secinfo/render"
+	"github.com/infosecstreams/secinfo/site"
+	"github.com/infosecstreams/secinfo/streamers"
+	"github.com/infosecstreams/secinfo/summary"
+	"github.com/spf13/afero"
+)
+
+// ErrTooManyFailures is returned when more of a list's lookups failed than the config's MaxFailures allows.
+var ErrTooManyFailures = errors.New("too many failed lookups")
+
+// Result is what a run did.
+type Result struct {
+	Lists []ListResult // A result per list, in the order of cfg.AllLists
+	Files []string     // The files written, in the order they were rendered
+}
+
+// ListResult is what a list's update did.
+type ListResult struct {
+	List     config.List     // The list's settings
+	Previous changes.Run     // The list as the last run left it
+	Current  changes.Run     // The list after this run
+	Events   []changes.Event // What changed since the last run
+	Report   summary.Report  // The run explained, including the streamers whose stats couldn't be fetched
+}
+
+// Run updates every list cfg configures and writes their outputs to fileSystem. The providers are asked for each
+// streamer's hours, and for the categories they stream in if cfg.DeriveTags is set.
+// If ctx is done, or cfg.Timeout passes, before every list is rendered, nothing is written and an error wrapping
+// the context's error is returned. Once the outputs are being written, they're all written.
+// If a list has too many failed lookups nothing is written either, and the error wraps ErrTooManyFailures
+// with a Result holding that list's report, so the failures can still be explained.
+func Run(ctx context.Context, cfg config.Config, fileSystem afero.Fs, providers []streamers.StatsProvider) (Result, error) {
+	if timeout := time.Duration(cfg.Timeout); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, timeout, fmt.Errorf("run took longer than the %s timeout: %w", timeout, context.DeadlineExceeded))
+		defer cancel()
+	}
+
+	// Share one cache between the lists so a streamer on several of them is only looked up once
+	// and tag them by the category they stream in most if the config asks to
+	var cache streamers.StatsCache
+	var categories []streamers.CategoryProvider
+	cached := make([]streamers.StatsProvider, len(providers))
+	for i, p := range providers {
+		if cp, ok := p.(streamers.CategoryProvider); ok && cfg.DeriveTags {
+			categories = append(categories, cache.Categories(cp))
+		}
+		cached[i] = cache.Provider(p)
+	}
+
+	var batch output.Batch
+	var result Result
+	for _, list := range cfg.AllLists() {
+		r, err := runList(ctx, fileSystem, &batch, cfg, list, cached, categories)
+		if ctx.Err() != nil {
+			return Result{}, stopped(ctx)
+		}
+		if err != nil {
+			// Only the list that stopped the run has a report worth reading, the others weren't written either
+			failed := Result{}
+			if errors.Is(err, ErrTooManyFailures) {
+				failed.Lists = []ListResult{r}
+			}
+			if list.Name != "" {
+				return failed, fmt.Errorf("list %s: %w", list.Name, err)
+			}
+			return failed, err
+		}
+		result.Lists = append(result.Lists, r)
+	}
+
+	// Only now that every list rendered, swap them all into place
+	if ctx.Err() != nil {
+		return Result{}, stopped(ctx)
+	}
+	if err := batch.Commit(fileSystem); err != nil {
+		return Result{}, err
+	}
+	result.Files = batch.Paths()
+	return result, nil
+}
+
+// stopped is the error of a run whose ctx is done before its outputs were written.
+func stopped(ctx context.Context) error {
+	return fmt.Errorf("run stopped before anything was written: %w", context.Cause(ctx))
+}
+
+// runList updates a streamer list and adds its outputs to batch.
+func runList(ctx context.Context, fileSystem afero.Fs, batch *output.Batch, cfg config.Config, list config.List, providers []streamers.StatsProvider, categories []streamers.CategoryProvider) (ListResult, error) {
+	active := streamers.StreamerList{}
+	inactive := streamers.StreamerList{}
+	var failures []summary.Failure
+	var warnings []string
+	var keptPrevious bool
+
+	// Keep the lists from the last run around to see what changed
+	previous, err := readRun(fileSystem, list.Paths)
+	if err != nil {
+		return ListResult{}, err
+	}
+
+	if !cfg.Test {
+		f, err := streamers.OpenCSVWithFS(fileSystem, list.Paths.Streamers)
+		if err != nil {
+			return ListResult{}, fmt.Errorf("reading %s: %w", list.Paths.Streamers, err)
+		}
+		defer f.Close()
+
+		// A csv that can't be parsed fails the run, carrying on would write the list without its streamers
+		activeFromFile, err := streamers.ParseStreamers(f)
+		if err != nil && !errors.Is(err, streamers.ErrEmptyCSV) {
+			return ListResult{}, fmt.Errorf("reading %s: %w", list.Paths.Streamers, err)
+		}
+
+		inactiveFromFile := streamers.StreamerList{}
+		inactiveFile, err := streamers.OpenCSVWithFS(fileSystem, list.Paths.Inactive)
+		if err == nil {
+			defer inactiveFile.Close()
+			inactiveFromFile, err = streamers.ParseStreamers(inactiveFile)
+			if err != nil && !errors.Is(err, streamers.ErrEmptyCSV) {
+				return ListResult{}, fmt.Errorf("reading %s: %w", list.Paths.Inactive, err)
+			}
+			for i := range inactiveFromFile.Streamers {
+				inactiveFromFile.Streamers[i].WasInactive = true
+			}
+		} else if !os.IsNotExist(err) {
+			return ListResult{}, fmt.Errorf("reading %s: %w", list.Paths.Inactive, err)
+		}
+
+		// Lists that came out empty when the last run had streamers were most likely truncated or wiped,
+		// there are no stats to keep from the last run so stop whatever the policy
+		if activeFromFile.Len() == 0 && inactiveFromFile.Len() == 0 && len(previous.Active)+len(previous.Inactive) > 0 {
+			err := fmt.Errorf("%w: %s and %s are empty but the last run had %d streamers", ErrTooManyFailures, list.Paths.Streamers, list.Paths.Inactive, len(previous.Active)+len(previous.Inactive))
+			return stoppedList(cfg, list, previous, nil, nil, err), err
+		}
+
+		// Only process active streamers from streamers.csv for stats
+		// Inactive streamers are kept as-is without checking stats
+		fetched := activeFromFile.Streamers
+		failed := make([]bool, len(fetched))
+		for i := range fetched {
+			streamer := &fetched[i]
+			if err := ctx.Err(); err != nil {
+				return ListResult{}, err
+			}
+			// Populate the streamer struct with the hours every platform's provider has
+			if err := streamer.CollectStats(ctx, providers...); err != nil {
+				failures = append(failures, summary.Failure{Name: streamer.Name, Err: err})
+				failed[i] = true
+			}
+			if len(categories) > 0 {
+				if err := streamer.DeriveTags(ctx, cfg.Categories(), categories...); err != nil {
+					warnings = append(warnings, fmt.Sprintf("deriving tags for %s: %s", streamer.Name, err))
+				}
+			}
+		}
+
+		// So many failures means the stats source is down rather than streamers gone,
+		// don't let the whole list drop to inactive
+		if cfg.TooManyFailures(len(failures), len(fetched)) {
+			if cfg.FailurePolicy != config.Previous {
+				err := fmt.Errorf("%w: %d of %d lookups failed, more than %v%%", ErrTooManyFailures, len(failures), len(fetched), cfg.MaxFailures)
+				return stoppedList(cfg, list, previous, failures, warnings, err), err
+			}
+			keepPrevious(previous, fetched, failed)
+			keptPrevious = true
+		}
+
+		for _, streamer := range fetched {
+			// Append the streamer to the new streamerList
+			if list.Active(streamer.ThirtyDayStats) {
+				active.Streamers = append(active.Streamers, streamer)
+			} else {
+				inactive.Streamers = append(inactive.Streamers, streamer)
+			}
+		}
+
+		// Add all inactive streamers to the inactive list WITHOUT checking stats
+		// (they remain inactive until manually moved back to streamers.csv)
+		for _, streamer := range inactiveFromFile.Streamers {
+			inactive.Streamers = append(inactive.Streamers, streamer)
+		}
+	} else {
+		// Reuse the last run's active and inactive json
+		active.Streamers = previous.Active
+		inactive.Streamers = previous.Inactive
+	}
+
+	// Keep the json sorted by hours, ranks and movers come from that order. Each page picks its own order below.
+	active.Sort()
+	inactive.Sort()
+
+	// Markdown time!
+	// Read the existing index page into a string so online streamers stay online
+	indexMd, _ := afero.ReadFile(fileSystem, list.Paths.Index)
+	indexStr := string(indexMd)
+
+	for i := range active.Streamers {
+		active.Streamers[i].Online = active.Streamers[i].OnlineNow(indexStr)
+	}
+	for i := range inactive.Streamers {
+		inactive.Streamers[i].Online = false // Sorry inactive can't be online
+	}
+	now := time.Now().UTC()
+	carryHistory(previous, now, active.Streamers, inactive.Streamers)
+
+	// Record today's hours and ranks so the pages can show who is trending
+	hist, err := history.Load(fileSystem, list.Paths.History)
+	if err != nil {
+		return ListResult{}, err
+	}
+	hist.Record(changes.Run{Active: active.Streamers, Inactive: inactive.Streamers}, now)
+
+	activePage := render.NewPage(active)
+	activePage.Active, activePage.Inactive = len(active.Streamers), len(inactive.Streamers)
+	root := siteRoot(list.SiteURL)
+	links := render.Links{
+		Home:      pageLink(root, list.Paths.Index),
+		Inactive:  pageLink(root, list.Paths.InactivePage),
+		Languages: path.Join(root, list.Paths.Languages),
+	}
+	// newPage returns a page of sl with the list's counts, history and links
+	newPage := func(sl streamers.StreamerList) render.Page {
+		page := render.NewPage(sl)
+		page.Active, page.Inactive = activePage.Active, activePage.Inactive
+		page.Links = links
+		page.SetHistory(hist)
+		if list.Paths.Profiles != "" {
+			page.LinkProfiles(path.Join(root, list.Paths.Profiles))
+		}
+		if list.Paths.Charts != "" {
+			page.Chart = path.Join(root, list.Paths.Charts, "hours-per-week.svg")
+		}
+		return page
+	}
+	indexPage := newPage(active.Sorted(config.Order(list.Sort.Index)))
+	indexOut, err := render.Markdown(fileSystem, list.Paths.IndexTemplate, indexPage, partials(list.Paths.LinksTemplate)...)
+	if err != nil {
+		return ListResult{}, fmt.Errorf("rendering %s: %w", list.Paths.Index, err)
+	}
+
+	// Render a page per language so viewers can find streams they understand
+	if dir := list.Paths.Languages; dir != "" {
+		for i := range activePage.Languages {
+			languagePage := newPage(active.InLanguage(activePage.Languages[i].Tag).Sorted(config.Order(list.Sort.Index)))
+			languagePage.Language = &activePage.Languages[i]
+			out, err := render.Markdown(fileSystem, list.Paths.IndexTemplate, languagePage, partials(list.Paths.LinksTemplate)...)
+			if err != nil {
+				return ListResult{}, fmt.Errorf("rendering %s page: %w", languagePage.Language.Name, err)
+			}
+			batch.Add(path.Join(dir, languagePage.Language.Tag+".md"), out)
+		}
+	}
+
+	inactivePage := newPage(inactive.Sorted(config.Order(list.Sort.Inactive)))
+	inactiveOut, err := render.Markdown(fileSystem, list.Paths.InactiveTemplate, inactivePage, partials(list.Paths.LinksTemplate)...)
+	if err != nil {
+		return ListResult{}, fmt.Errorf("rendering %s: %w", list.Paths.InactivePage, err)
+	}
+
+	// Render a profile page per streamer from their history
+	if dir := list.Paths.Profiles; dir != "" {
+		for _, row := range append(append([]render.Row(nil), indexPage.Streamers...), inactivePage.Streamers...) {
+			profile := render.NewProfile(row, hist, now)
+			profile.Links = links
+			if list.Paths.Charts != "" {
+				profile.Chart = path.Join(root, list.Paths.Charts, "streamers", streamers.Slug(row.Name)+".svg")
+			}
+			out, err := render.ProfileMarkdown(fileSystem, list.Paths.ProfileTemplate, profile, partials(list.Paths.LinksTemplate)...)
+			if err != nil {
+				return ListResult{}, fmt.Errorf("rendering %s's profile: %w", row.Name, err)
+			}
+			batch.Add(path.Join(dir, streamers.Slug(row.Name)+".md"), out)
+		}
+	}
+
+	// Draw the charts the pages link to, test runs leave the last run's
+	if dir := list.Paths.Charts; dir != "" && !cfg.Test {
+		batch.Add(path.Join(dir, "hours-per-week.svg"), chart.HoursPerWeek(hist.WeeklyHours(cfg.WindowDays)).SVG())
+		for _, s := range append(append([]streamers.Streamer(nil), active.Streamers...), inactive.Streamers...) {
+			batch.Add(path.Join(dir, "streamers", streamers.Slug(s.Name)+".svg"), chart.Activity(s.Name, hist.Points(s.Name), cfg.WindowDays).SVG())
+		}
+	}
+
+	// Queue the fetched lists and history outside of test mode so the latest data is available
+	if !cfg.Test {
+		if err := batch.AddJSON(list.Paths.ActiveJSON, active); err != nil {
+			return ListResult{}, err
+		}
+		if err := batch.AddJSON(list.Paths.InactiveJSON, inactive); err != nil {
+			return ListResult{}, err
+		}
+		if err := batch.AddJSON(list.Paths.History, hist); err != nil {
+			return ListResult{}, err
+		}
+
+		// Queue updated CSV files, sorted by name for human readability
+		batch.Add(list.Paths.Streamers, active.CSV())
+		batch.Add(list.Paths.Inactive, inactive.CSV())
+	}
+	batch.Add(list.Paths.Index, indexOut)
+	batch.Add(list.Paths.InactivePage, inactiveOut)
+
+	current := changes.Run{Active: active.Streamers, Inactive: inactive.Streamers}
+	events := changes.Diff(previous, current)
+
+	// Publish the run to the feed, api, exports and calendars. Test runs have nothing new to publish.
+	if !cfg.Test {
+		// Add what changed since the last run to the Atom feed
+		existingFeed, err := afero.ReadFile(fileSystem, list.Paths.Feed)
+		if err != nil && !os.IsNotExist(err) {
+			return ListResult{}, err
+		}
+		atom, err := feed.Update(existingFeed, events, activePage.GeneratedAt, feed.Options{
+			Title:      list.Title,
+			SiteURL:    list.SiteURL,
+			Path:       list.Paths.Feed,
+			WindowDays: cfg.WindowDays,
+		})
+		if err != nil {
+			return ListResult{}, fmt.Errorf("updating %s: %w", list.Paths.Feed, err)
+		}
+		batch.Add(list.Paths.Feed, atom)
+
+		// Publish the versioned list for other sites to consume, always by hours and name so ranks stay stable
+		apiList := api.NewList(activePage, render.NewPage(inactive.Sorted(streamers.ByName)), activePage.GeneratedAt)
+		apiList.WindowDays = cfg.WindowDays
+		if err := api.Render(batch, list.Paths.API, apiList); err != nil {
+			return ListResult{}, err
+		}
+
+		// Export the active list for other tools in the configured formats
+		if err := export.Render(batch, list.Paths.Export, list.Title, active.Sorted(config.Order(list.Sort.Exports)), list.Exports...); err != nil {
+			return ListResult{}, err
+		}
+
+		// Export the active streamers' Twitch schedules if we have Twitch API credentials
+		if schedules := cfg.ScheduleProvider(); schedules != nil {
+			// A failed lookup would drop that streamer's streams, keep their events from the last calendars instead
+			found, failed := calendar.Fetch(ctx, schedules, active.Streamers)
+			for _, f := range failed {
+				warnings = append(warnings, fmt.Sprintf("fetching %s's schedule, kept their last calendar: %s", f.Streamer.Name, f.Err))
+			}
+			found, err := calendar.Keep(fileSystem, list.Paths.Calendar, found, failed)
+			if err != nil {
+				return ListResult{}, err
+			}
+			calendar.Render(batch, list.Paths.Calendar, list.Title, siteHost(list.SiteURL), found)
+		}
+	}
+
+	// Render the static HTML site too if the config says where to put it
+	if dir := list.Paths.HTMLDir; dir != "" {
+		if err := site.Render(batch, dir, newPage(active.Sorted(config.Order(list.Sort.HTML))), inactivePage, hist, site.Options{Title: list.Title, WindowDays: cfg.WindowDays, Sort: list.Sort.HTML}); err != nil {
+			return ListResult{}, fmt.Errorf("rendering html site: %w", err)
+		}
+	}
+
+	// Explain the run to whoever reviews it, with a pull request description if the config says where to write it
+	// and the run fetched anything
+	report := summary.New(previous, current, events, failures, activePage.GeneratedAt)
+	report.WindowDays = cfg.WindowDays
+	report.List = list.Name
+	report.KeptPrevious = keptPrevious
+	report.Warnings = warnings
+	if path := list.Paths.PRBody; path != "" && !cfg.Test {
+		batch.Add(path, report.PullRequest())
+	}
+
+	return ListResult{List: list, Previous: previous, Current: current, Events: events, Report: report}, nil
+}
+
+// stoppedList is the result of a list whose update stopped with err before anything was rendered.
+// Its report has the failed lookups, and the last run's lists since they're left as they were.
+func stoppedList(cfg config.Config, list config.List, previous changes.Run, failures []summary.Failure, warnings []string, err error) ListResult {
+	report := summary.New(previous, previous, nil, failures, time.Now().UTC())
+	report.WindowDays = cfg.WindowDays
+	report.List = list.Name
+	report.Stopped = err.Error()
+	report.Warnings = warnings
+	return ListResult{List: list, Previous: previous, Current: previous, Report: report}
+}
+
+// siteRoot returns the path the site at siteURL lives under, "/" for a site at the root of its host.
+func siteRoot(siteURL string) string {
+	u, err := url.Parse(siteURL)
+	if err != nil {
+		return "/"
+	}
+	return path.Join("/", u.Path)
+}
+
+// siteHost returns the host of the site at siteURL, which names the list in calendar event UIDs.
+func siteHost(siteURL string) string {
+	u, err := url.Parse(siteURL)
+	if err != nil || u.Hostname() == "" {
+		return "localhost"
+	}
+	return u.Hostname()
+}
+
+// pageLink returns the link to the page rendered to file on the site under root: file without its extension,
+// or its directory for an index page.
+func pageLink(root, file string) string {
+	page := strings.TrimSuffix(file, path.Ext(file))
+	if path.Base(page) != "index" {
+		return path.Join(root, page)
+	}
+	if dir := path.Join(root, path.Dir(page)); dir != "/" {
+		return dir + "/"
+	}
+	return "/"
+}
+
+// carryHistory keeps when each streamer was added and last seen live from the previous run.
+// Streamers the previous run didn't have were added now, unless there's no previous run to tell.
+func carryHistory(previous changes.Run, now time.Time, lists ...[]streamers.Streamer) {
+	known := map[string]streamers.Streamer{}
+	for _, s := range append(append([]streamers.Streamer(nil), previous.Active...), previous.Inactive...) {
+		known[strings.ToLower(s.Name)] = s
+	}
+	for _, l := range lists {
+		for i := range l {
+			s := &l[i]
+			if prev, ok := known[strings.ToLower(s.Name)]; ok {
+				s.Added, s.LastLive = prev.Added, prev.LastLive
+			} else if len(known) > 0 {
+				s.Added = now
+			}
+			if s.Online {
+				s.LastLive = now
+			}
+		}
+	}
+}
+
+// keepPrevious gives the streamers whose lookup failed their hours from the last run, if it had them.
+func keepPrevious(previous changes.Run, fetched []streamers.Streamer, failed []bool) {
+	known := map[string]streamers.Streamer{}
+	for _, s := range append(append([]streamers.Streamer(nil), previous.Active...), previous.Inactive...) {
+		known[strings.ToLower(s.Name)] = s
+	}
+	for i := range fetched {
+		prev, ok := known[strings.ToLower(fetched[i].Name)]
+		if !ok || !failed[i] {
+			continue
+		}
+		fetched[i].ThirtyDayStats, fetched[i].StreamLengths = prev.ThirtyDayStats, prev.StreamLengths
+		if fetched[i].SullyGnomeID == "" {
+			fetched[i].SullyGnomeID = prev.SullyGnomeID
+		}
+	}
+}
+
+// readRun reads the lists the last run left in the active and inactive json. Missing files are empty lists.
+func readRun(fileSystem afero.Fs, paths config.Paths) (changes.Run, error) {
+	var run changes.Run
+	for file, list := range map[string]*[]streamers.Streamer{paths.ActiveJSON: &run.Active, paths.InactiveJSON: &run.Inactive} {
+		data, err := afero.ReadFile(fileSystem, file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return run, err
+		}
+		var sl streamers.StreamerList
+		if err := json.Unmarshal(data, &sl); err != nil {
+			return run, fmt.Errorf("reading %s: %w", file, err)
+		}
+		*list = sl.Streamers
+	}
+	return run, nil
+}
+
+// partials returns the partial template files that are configured.
+func partials(paths ...string) []string {
+	var files []string
+	for _, path := range paths {
+		if path != "" {
+			files = append(files, path)
+		}
+	}
+	return files
+}
@@ -0,0 +1,326 @@
+package pipeline_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/infosecstreams/secinfo/changes"
+	"github.com/infosecstreams/secinfo/config"
+	"github.com/infosecstreams/secinfo/pipeline"
+	"github.com/infosecstreams/secinfo/streamers"
+	"github.com/infosecstreams/secinfo/sullygnometest"
+	"github.com/spf13/afero"
+)
+
+const (
+	indexTemplate    = "{{range .Streamers}}`{{.Name}}` {{template \"links\" .}}\n{{end}}"
+	inactiveTemplate = "{{range .Streamers}}`{{.Name}}`\n{{end}}"
+	linksTemplate    = "{{define \"links\"}}{{range .Accounts}}[{{.Platform}}]({{.URL}}){{end}}{{end}}"
+)
+
+// hours is a StatsProvider with a fixed number of hours per streamer, and an error for the ones it doesn't know.
+// A negative number of hours blocks until the lookup is canceled.
+type hours map[string]float32
+
+func (hours) Platform() streamers.Platform {
+	return streamers.Twitch
+}
+
+func (h hours) Hours(ctx context.Context, s *streamers.Streamer, a streamers.Account) (float32, error) {
+	n, ok := h[a.Handle]
+	if ok && n < 0 {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+	if ok {
+		return n, nil
+	}
+	return 0, errors.New("not found")
+}
+
+// noCategories has hours like hours, but fails every category lookup.
+type noCategories struct{ hours }
+
+func (noCategories) TopCategory(ctx context.Context, s *streamers.Streamer, a streamers.Account) (string, error) {
+	return "", errors.New("categories down")
+}
+
+func newFs(t *testing.T) afero.Fs {
+	t.Helper()
+	fs := afero.NewMemMapFs()
+	write(t, fs, "templates/index.tmpl.md", indexTemplate)
+	write(t, fs, "templates/inactive.tmpl.md", inactiveTemplate)
+	write(t, fs, "templates/links.tmpl", linksTemplate)
+	write(t, fs, "streamers.csv", "alice,\nbob,\ncarol,\n")
+	write(t, fs, "inactive_streamers.csv", "dave,\n")
+	return fs
+}
+
+func TestRun(t *testing.T) {
+	fs := newFs(t)
+	result, err := pipeline.Run(context.Background(), config.Default(), fs, []streamers.StatsProvider{hours{"alice": 5, "bob": 20}})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(result.Lists) != 1 {
+		t.Fatalf("Got: %d lists, Wanted: 1", len(result.Lists))
+	}
+	r := result.Lists[0]
+	var active, inactive []string
+	for _, s := range r.Current.Active {
+		active = append(active, s.Name)
+	}
+	for _, s := range r.Current.Inactive {
+		inactive = append(inactive, s.Name)
+	}
+	if !slices.Equal(active, []string{"bob", "alice"}) || !slices.Equal(inactive, []string{"carol", "dave"}) {
+		t.Errorf("Got: %v and %v, Wanted: [bob alice] and [carol dave]", active, inactive)
+	}
+	if len(r.Report.Failures) != 1 || r.Report.Failures[0].Name != "carol" {
+		t.Errorf("Got: %+v, Wanted carol's failed lookup in the report", r.Report.Failures)
+	}
+
+	for _, file := range []string{"index.md", "inactive.md", "active.json", "inactive.json", "history.json", "streamers.csv"} {
+		if !slices.Contains(result.Files, file) {
+			t.Errorf("Got: %v, Wanted %s among the files written", result.Files, file)
+		}
+		if ok, _ := afero.Exists(fs, file); !ok {
+			t.Errorf("%s wasn't written", file)
+		}
+	}
+	if index := read(t, fs, "index.md"); !strings.HasPrefix(index, "`bob` [twitch]") || !strings.Contains(index, "`alice`") {
+		t.Errorf("Got: %s, Wanted bob then alice", index)
+	}
+	var sl streamers.StreamerList
+	if err := json.Unmarshal([]byte(read(t, fs, "active.json")), &sl); err != nil || len(sl.Streamers) != 2 || sl.Streamers[0].ThirtyDayStats != 20 {
+		t.Errorf("Got: %+v, %v, Wanted bob's 20 hours first in active.json", sl.Streamers, err)
+	}
+}
+
+func TestRunLinksTheListsPages(t *testing.T) {
+	fs := newFs(t)
+	write(t, fs, "templates/index.tmpl.md", "{{.Links.Home}} {{.Links.Inactive}} {{.Links.Languages}}{{range .Streamers}}{{end}}")
+	cfg := config.Default()
+	cfg.SiteURL = "https://example.com/streams/"
+	cfg.Paths.Index, cfg.Paths.InactivePage, cfg.Paths.Languages = "ctf/index.md", "ctf/inactive.md", "ctf/lang"
+	if _, err := pipeline.Run(context.Background(), cfg, fs, []streamers.StatsProvider{hours{"alice": 5, "bob": 20, "carol": 1}}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if got, want := read(t, fs, "ctf/index.md"), "/streams/ctf/ /streams/ctf/inactive /streams/ctf/lang"; got != want {
+		t.Errorf("Got: %q, Wanted: %q", got, want)
+	}
+}
+
+func TestRunReportsWarnings(t *testing.T) {
+	fs := newFs(t)
+	cfg := config.Default()
+	cfg.DeriveTags = true
+	result, err := pipeline.Run(context.Background(), cfg, fs, []streamers.StatsProvider{noCategories{hours{"alice": 5, "bob": 20, "carol": 1}}})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	warnings := result.Lists[0].Report.Warnings
+	if len(warnings) != 3 || !strings.Contains(warnings[0], "deriving tags for alice: ") || !strings.Contains(warnings[0], "categories down") {
+		t.Errorf("Got: %q, Wanted a warning per streamer whose tags couldn't be derived", warnings)
+	}
+}
+
+func TestRunTestMode(t *testing.T) {
+	fs := newFs(t)
+	write(t, fs, "active.json", `{"streamers":[{"name":"erin","thirtydaystats":3}]}`)
+	cfg := config.Default()
+	cfg.Test = true
+	cfg.Paths.PRBody = "pr_body.md"
+	cfg.TwitchID, cfg.TwitchToken = "id", "token"
+
+	result, err := pipeline.Run(context.Background(), cfg, fs, []streamers.StatsProvider{hours{}})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if index := read(t, fs, "index.md"); !strings.Contains(index, "`erin`") {
+		t.Errorf("Got: %s, Wanted the last run's streamers", index)
+	}
+	if slices.Contains(result.Files, "active.json") || read(t, fs, "streamers.csv") != "alice,\nbob,\ncarol,\n" {
+		t.Errorf("Got: %v, Wanted the data files left alone in test mode", result.Files)
+	}
+	// Nothing is published from a test run
+	for _, file := range result.Files {
+		for _, published := range []string{"atom.xml", "api/", "export/", "calendar/", "charts/", "pr_body.md"} {
+			if strings.HasPrefix(file, published) {
+				t.Errorf("Got: %s, Wanted nothing under %s written in test mode", file, published)
+			}
+		}
+	}
+	for _, path := range []string{"atom.xml", "api", "export", "calendar", "charts", "pr_body.md"} {
+		if ok, _ := afero.Exists(fs, path); ok {
+			t.Errorf("%s shouldn't be written in test mode", path)
+		}
+	}
+}
+
+func TestRunWritesNothingOnError(t *testing.T) {
+	fs := newFs(t)
+	write(t, fs, "index.md", "old index")
+	write(t, fs, "templates/inactive.tmpl.md", "{{.Missing")
+
+	if _, err := pipeline.Run(context.Background(), config.Default(), fs, []streamers.StatsProvider{hours{}}); err == nil {
+		t.Fatalf("Run should fail with a broken template")
+	}
+	if index := read(t, fs, "index.md"); index != "old index" {
+		t.Errorf("Got: %s, Wanted the old index left untouched", index)
+	}
+	if ok, _ := afero.Exists(fs, "active.json"); ok {
+		t.Errorf("active.json shouldn't be written")
+	}
+}
+
+func TestRunFailsOnMalformedCSV(t *testing.T) {
+	for file, content := range map[string]string{
+		"streamers.csv":          "alice,\nbob,,lang=zz-notalang-x\ncarol,\n",
+		"inactive_streamers.csv": "dave,,tiktok=dave\n",
+	} {
+		fs := newFs(t)
+		write(t, fs, file, content)
+
+		_, err := pipeline.Run(context.Background(), config.Default(), fs, []streamers.StatsProvider{hours{"alice": 5, "bob": 20}})
+		if err == nil || !strings.Contains(err.Error(), file) {
+			t.Errorf("Got: %v, Wanted an error reading %s", err, file)
+		}
+		if got := read(t, fs, file); got != content {
+			t.Errorf("Got: %q, Wanted %s untouched", got, file)
+		}
+		if ok, _ := afero.Exists(fs, "active.json"); ok {
+			t.Errorf("active.json shouldn't be written")
+		}
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	fs := newFs(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := pipeline.Run(ctx, config.Default(), fs, []streamers.StatsProvider{hours{}}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Got: %v, Wanted: %v", err, context.Canceled)
+	}
+	if ok, _ := afero.Exists(fs, "index.md"); ok {
+		t.Errorf("index.md shouldn't be written")
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	fs := newFs(t)
+	cfg := config.Default()
+	cfg.Timeout = config.Duration(50 * time.Millisecond)
+
+	_, err := pipeline.Run(context.Background(), cfg, fs, []streamers.StatsProvider{hours{"alice": 5, "bob": -1}})
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "50ms timeout") {
+		t.Errorf("Got: %v, Wanted the run to stop at its timeout", err)
+	}
+	if ok, _ := afero.Exists(fs, "active.json"); ok {
+		t.Errorf("active.json shouldn't be written")
+	}
+}
+
+func TestRunRequestTimeout(t *testing.T) {
+	server := sullygnometest.NewServer(
+		sullygnometest.Channel{Name: "alice", StreamLengths: []float32{2, 3, 1}},
+		sullygnometest.Channel{Name: "bob", StreamLengths: []float32{0, 2}, Failure: sullygnometest.Slow},
+		sullygnometest.Channel{Name: "carol", StreamLengths: []float32{1}},
+	)
+	defer server.Close()
+	server.Delay = 5 * time.Second
+	cfg := config.Default()
+	cfg.SullyGnomeURL = server.URL
+	cfg.RequestTimeout = config.Duration(100 * time.Millisecond)
+	cfg.MaxFailures = 100
+	providers, err := cfg.StatsProviders()
+	if err != nil {
+		t.Fatalf("StatsProviders failed: %v", err)
+	}
+
+	// A hung request fails on its own and the rest of the run goes on
+	result, err := pipeline.Run(context.Background(), cfg, newFs(t), providers)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	r := result.Lists[0]
+	if len(r.Current.Active) != 2 || len(r.Report.Failures) != 1 || r.Report.Failures[0].Name != "bob" {
+		t.Errorf("Got: %+v and failures %+v, Wanted alice and carol active after bob's lookup timed out", r.Current.Active, r.Report.Failures)
+	}
+}
+
+func TestRunTooManyFailures(t *testing.T) {
+	fs := newFs(t)
+	write(t, fs, "active.json", `{"streamers":[{"name":"alice","thirtydaystats":5},{"name":"bob","thirtydaystats":20}]}`)
+	down := []streamers.StatsProvider{hours{"carol": 1}}
+
+	stopped, err := pipeline.Run(context.Background(), config.Default(), fs, down)
+	if !errors.Is(err, pipeline.ErrTooManyFailures) || !strings.Contains(err.Error(), "2 of 3") {
+		t.Errorf("Got: %v, Wanted 2 of 3 lookups to be too many", err)
+	}
+	if len(stopped.Lists) != 1 || len(stopped.Lists[0].Report.Failures) != 2 || stopped.Lists[0].Report.Stopped == "" {
+		t.Errorf("Got: %+v, Wanted the stopped list's report with its 2 failures", stopped.Lists)
+	}
+	if ok, _ := afero.Exists(fs, "index.md"); ok {
+		t.Errorf("index.md shouldn't be written")
+	}
+
+	cfg := config.Default()
+	cfg.FailurePolicy = config.Previous
+	result, err := pipeline.Run(context.Background(), cfg, fs, down)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	r := result.Lists[0]
+	if !r.Report.KeptPrevious || len(r.Current.Active) != 3 || r.Current.Active[0].Name != "bob" || r.Current.Active[0].ThirtyDayStats != 20 {
+		t.Errorf("Got: %+v, Wanted alice and bob to keep their last hours", r.Current.Active)
+	}
+	if demoted := changes.Filter(r.Events, changes.Demoted); len(demoted) != 0 {
+		t.Errorf("Got: %+v, Wanted no demotions", demoted)
+	}
+
+	cfg.MaxFailures = 100
+	result, err = pipeline.Run(context.Background(), cfg, fs, down)
+	if err != nil || result.Lists[0].Report.KeptPrevious || len(result.Lists[0].Current.Active) != 1 {
+		t.Errorf("Got: %+v, %v, Wanted the failures to count as no hours below the threshold", result.Lists, err)
+	}
+}
+
+func TestRunEmptyListsAfterARun(t *testing.T) {
+	fs := newFs(t)
+	write(t, fs, "active.json", `{"streamers":[{"name":"alice","thirtydaystats":5}]}`)
+	write(t, fs, "streamers.csv", "")
+	write(t, fs, "inactive_streamers.csv", "")
+
+	cfg := config.Default()
+	cfg.FailurePolicy = config.Previous
+	if _, err := pipeline.Run(context.Background(), cfg, fs, []streamers.StatsProvider{hours{}}); !errors.Is(err, pipeline.ErrTooManyFailures) {
+		t.Errorf("Got: %v, Wanted: %v", err, pipeline.ErrTooManyFailures)
+	}
+	if got := read(t, fs, "active.json"); !strings.Contains(got, "alice") {
+		t.Errorf("Got: %s, Wanted the last run's active.json left alone", got)
+	}
+}
+
+func write(t *testing.T, fs afero.Fs, path, content string) {
+	t.Helper()
+	if err := afero.WriteFile(fs, path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+}
+
+func read(t *testing.T, fs afero.Fs, path string) string {
+	t.Helper()
+	data, err := afero.ReadFile(fs, path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	return string(data)
+}
@@ -0,0 +1,250 @@
+/* Package render executes the markdown page templates with the streamer lists. */
+package render
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/infosecstreams/secinfo/history"
+	"github.com/infosecstreams/secinfo/streamers"
+	"github.com/spf13/afero"
+)
+
+// ErrNoStreamers is returned for a page template that never uses .Streamers, i.e. one
+// that would render without the streamer table.
+var ErrNoStreamers = errors.New("template never lists .Streamers")
+
+// NewWithin is how recently a streamer has to have been added to be listed as new.
+const NewWithin = 7 * 24 * time.Hour
+
+// Row is a single streamer as seen by a page template.
+type Row struct {
+	streamers.Streamer        // The streamer, with their accounts, stats and online status
+	Rank               int    // 1-based position on the active list by hours, 0 if inactive or without history
+	PreviousRank       int    // Rank as of the day before, 0 if the streamer wasn't active then
+	Trend              string // A sparkline of the streamer's hours over the last days, empty without history
+	Profile            string // The link to the streamer's profile page, empty if there isn't one
+	known              bool   // Whether there was an earlier day to compare with
+}
+
+// Movement is how the streamer's rank changed since the day before: "up", "down", "new" to the active list, or empty.
+func (r Row) Movement() string {
+	switch {
+	case r.Rank == 0 || !r.known:
+		return ""
+	case r.PreviousRank == 0:
+		return "new"
+	case r.Rank < r.PreviousRank:
+		return "up"
+	case r.Rank > r.PreviousRank:
+		return "down"
+	}
+	return ""
+}
+
+// Section is the streamers with a tag, for grouping a page by topic.
+type Section struct {
+	Tag       streamers.Tag // The topic, {{.Tag.Title}} is its display name
+	Streamers []Row         // The streamers with the tag, in display order
+}
+
+// Language is a language some of a page's streamers stream in.
+type Language struct {
+	Tag       string // The base language, e.g. "pt" for streamers in "pt" and "pt-BR"
+	Name      string // The language's name in itself, e.g. "Português"
+	Streamers int    // How many of the page's streamers stream in it
+}
+
+// Links are where a list's pages are on its site, so its pages can link to each other.
+type Links struct {
+	Home      string // The list's index page
+	Inactive  string // The inactive page
+	Languages string // The directory of the per-language pages, a language's page is Languages/Tag
+}
+
+// DefaultLinks are the links of a list with the default paths at the root of its site.
+var DefaultLinks = Links{Home: "/", Inactive: "/inactive", Languages: "/lang"}
+
+// Page is the data a page template is executed with.
+type Page struct {
+	Streamers   []Row      // The streamers to list, in display order
+	Sections    []Section  // The streamers grouped by tag, in the order of streamers.Tags, skipping empty ones
+	Languages   []Language // The languages the streamers stream in, most streamers first
+	Language    *Language  // The language a per-language page is for, nil on a page of every language
+	New         []Row      // The streamers added within NewWithin, newest first
+	Chart       string     // The link to the chart of the list's hours per week, empty if there isn't one
+	Links       Links      // Where the list's pages are, DefaultLinks unless set
+	Active      int        // Number of active streamers
+	Inactive    int        // Number of inactive streamers
+	GeneratedAt time.Time  // When the page was rendered
+}
+
+// NewPage returns a Page listing sl in order.
+func NewPage(sl streamers.StreamerList) Page {
+	page := Page{Links: DefaultLinks, GeneratedAt: time.Now().UTC()}
+	for _, s := range sl.Streamers {
+		page.Streamers = append(page.Streamers, Row{Streamer: s})
+	}
+	page.group()
+	return page
+}
+
+// SetHistory sets every row's ranks and trend from h.
+func (p *Page) SetHistory(h history.History) {
+	for i := range p.Streamers {
+		row := &p.Streamers[i]
+		row.Rank, row.PreviousRank, row.known = h.Ranks(row.Name)
+		row.Trend = h.Trend(row.Name)
+	}
+	p.group()
+}
+
+// LinkProfiles links every row to its profile page in the directory root, e.g. "/streamers".
+func (p *Page) LinkProfiles(root string) {
+	for i := range p.Streamers {
+		p.Streamers[i].Profile = path.Join(root, streamers.Slug(p.Streamers[i].Name))
+	}
+	p.group()
+}
+
+// group sets the sections, languages and new streamers from the rows.
+func (p *Page) group() {
+	p.Sections, p.Languages, p.New = nil, nil, nil
+	for _, t := range streamers.Tags {
+		section := Section{Tag: t}
+		for _, row := range p.Streamers {
+			if row.HasTag(t) {
+				section.Streamers = append(section.Streamers, row)
+			}
+		}
+		if len(section.Streamers) > 0 {
+			p.Sections = append(p.Sections, section)
+		}
+	}
+
+	counts := map[string]int{}
+	for _, row := range p.Streamers {
+		if base := streamers.LangBase(row.Lang); base != "" && base != "und" {
+			counts[base]++
+		}
+	}
+	for tag, n := range counts {
+		p.Languages = append(p.Languages, Language{Tag: tag, Name: streamers.LangName(tag), Streamers: n})
+	}
+	sort.Slice(p.Languages, func(i, j int) bool {
+		if p.Languages[i].Streamers != p.Languages[j].Streamers {
+			return p.Languages[i].Streamers > p.Languages[j].Streamers
+		}
+		return p.Languages[i].Tag < p.Languages[j].Tag
+	})
+
+	for _, row := range p.Streamers {
+		if !row.Added.IsZero() && p.GeneratedAt.Sub(row.Added) < NewWithin {
+			p.New = append(p.New, row)
+		}
+	}
+	sort.SliceStable(p.New, func(i, j int) bool { return p.New[i].Added.After(p.New[j].Added) })
+}
+
+// Profile is the data a streamer's profile page template is executed with.
+type Profile struct {
+	Row                         // The streamer, with their rank and trend
+	Points      []history.Point // The streamer's hours and rank over time, oldest first
+	Days        []time.Weekday  // The days the streamer is usually live on, UTC, empty if never seen live
+	Hours       []int           // The hours of the day the streamer is usually live at, UTC, empty if never seen live
+	LiveRuns    int             // How many runs saw the streamer live, the only moments Days and Hours are known from
+	Chart       string          // The link to the chart of the streamer's hours, empty if there isn't one
+	Links       Links           // Where the list's pages are, DefaultLinks unless set
+	GeneratedAt time.Time       // When the page was rendered
+}
+
+// NewProfile returns the profile of the streamer in row with their history from h.
+func NewProfile(row Row, h history.History, generatedAt time.Time) Profile {
+	seen := h.Seen(row.Name)
+	days, hours := seen.Usual()
+	return Profile{Row: row, Points: h.Points(row.Name), Days: days, Hours: hours, LiveRuns: seen.Runs(), Links: DefaultLinks, GeneratedAt: generatedAt}
+}
+
+// Markdown executes the page template at file with page and returns the result.
+// Any partials are parsed alongside it so their {{define}} blocks can be shared between pages.
+func Markdown(fileSystem afero.Fs, file string, page Page, partials ...string) ([]byte, error) {
+	tmpl, err := parseTemplate(fileSystem, file, partials)
+	if err != nil {
+		return nil, err
+	}
+	if tmpl.Tree == nil || !usesField(tmpl.Tree.Root, "Streamers") {
+		return nil, fmt.Errorf("%s: %w", file, ErrNoStreamers)
+	}
+	return execute(tmpl, page)
+}
+
+// ProfileMarkdown executes the profile template at file with profile and returns the result.
+func ProfileMarkdown(fileSystem afero.Fs, file string, profile Profile, partials ...string) ([]byte, error) {
+	tmpl, err := parseTemplate(fileSystem, file, partials)
+	if err != nil {
+		return nil, err
+	}
+	return execute(tmpl, profile)
+}
+
+func parseTemplate(fileSystem afero.Fs, file string, partials []string) (*template.Template, error) {
+	return template.New(path.Base(file)).ParseFS(afero.NewIOFS(fileSystem), append([]string{file}, partials...)...)
+}
+
+func execute(tmpl *template.Template, data any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// usesField reports whether any pipeline under node reads the top-level field name,
+// e.g. {{range .Streamers}} or {{len $.Streamers}}.
+func usesField(node parse.Node, name string) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		for _, child := range n.Nodes {
+			if usesField(child, name) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return usesField(n.Pipe, name)
+	case *parse.IfNode:
+		return usesField(&n.BranchNode, name)
+	case *parse.RangeNode:
+		return usesField(&n.BranchNode, name)
+	case *parse.WithNode:
+		return usesField(&n.BranchNode, name)
+	case *parse.BranchNode:
+		return usesField(n.Pipe, name) || usesField(n.List, name) || usesField(n.ElseList, name)
+	case *parse.TemplateNode:
+		return usesField(n.Pipe, name)
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+		for _, cmd := range n.Cmds {
+			for _, arg := range cmd.Args {
+				if usesField(arg, name) {
+					return true
+				}
+			}
+		}
+	case *parse.FieldNode:
+		return len(n.Ident) > 0 && n.Ident[0] == name
+	case *parse.VariableNode:
+		return len(n.Ident) > 1 && n.Ident[0] == "$" && n.Ident[1] == name
+	}
+	return false
+}
@@ -0,0 +1,232 @@
+package render_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/infosecstreams/secinfo/changes"
+	"github.com/infosecstreams/secinfo/history"
+	"github.com/infosecstreams/secinfo/render"
+	"github.com/infosecstreams/secinfo/streamers"
+	"github.com/spf13/afero"
+)
+
+func templatesFS(t *testing.T) afero.Fs {
+	t.Helper()
+
+	// Use the real templates so changes to them are covered too
+	return afero.NewReadOnlyFs(afero.NewBasePathFs(afero.NewOsFs(), ".."))
+}
+
+func TestMarkdownIndex(t *testing.T) {
+	alice := streamers.Streamer{Name: "alice", ThirtyDayStats: 3, Lang: "EN", Online: true, Tags: []streamers.Tag{streamers.CTF, streamers.RedTeam}}
+	alice.SetAccount(streamers.Account{Platform: streamers.Twitch, Handle: "alice"})
+	alice.SetAccount(streamers.Account{Platform: streamers.Kick, Handle: "alice"})
+	bob := streamers.Streamer{Name: "bob", ThirtyDayStats: 1}
+	bob.SetAccount(streamers.Account{Platform: streamers.Twitch, Handle: "bob"})
+	bob.SetAccount(streamers.Account{Platform: streamers.YouTube, URL: "https://www.youtube.com/channel/UC123"})
+
+	sl := streamers.StreamerList{Streamers: []streamers.Streamer{alice, bob}}
+	page := render.NewPage(sl)
+	out, err := render.Markdown(templatesFS(t), "templates/index.tmpl.md", page, "templates/links.tmpl")
+	if err != nil {
+		t.Fatalf("Markdown failed: %v", err)
+	}
+
+	aliceLinks := "[<i class=\"fab fa-twitch\" style=\"color:#9146FF\"></i>](https://www.twitch.tv/alice) &nbsp; [<i class=\"fas fa-play-circle\" style=\"color:#53FC18\"></i>](https://kick.com/alice)"
+	want := "---: | --- | :---: | :--- | :--- | :---\n" +
+		"🟢 | `alice` |  | " + aliceLinks + " | Red Team, CTF | EN\n" +
+		"&nbsp; | `bob` |  | [<i class=\"fab fa-twitch\" style=\"color:#9146FF\"></i>](https://www.twitch.tv/bob) &nbsp; [<i class=\"fab fa-youtube\" style=\"color:#C00\"></i>](https://www.youtube.com/channel/UC123) |  |\n" +
+		"\n## Streams by Topic\n" +
+		"\n### Red Team\n\n- `alice` " + aliceLinks + "\n" +
+		"\n### CTF\n\n- `alice` " + aliceLinks + "\n" +
+		"\n### Useful links"
+	if !strings.Contains(string(out), want) {
+		t.Fatalf("index.md is missing rows, got:\n%s", out)
+	}
+}
+
+func TestMarkdownInactive(t *testing.T) {
+	carol := streamers.Streamer{Name: "carol"}
+	carol.SetAccount(streamers.Account{Platform: streamers.Twitch, Handle: "carol"})
+
+	page := render.NewPage(streamers.StreamerList{Streamers: []streamers.Streamer{carol}})
+	out, err := render.Markdown(templatesFS(t), "templates/inactive.tmpl.md", page, "templates/links.tmpl")
+	if err != nil {
+		t.Fatalf("Markdown failed: %v", err)
+	}
+
+	want := "--: | ---\n`carol` | [<i class=\"fab fa-twitch\" style=\"color:#9146FF\"></i>](https://www.twitch.tv/carol)\n\n### Credits"
+	if !strings.Contains(string(out), want) {
+		t.Fatalf("inactive.md is missing rows, got:\n%s", out)
+	}
+}
+
+func TestMarkdownFail(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "broken.tmpl.md", []byte("{{range .Streamers}}"), 0644)
+	afero.WriteFile(fs, "unknown.tmpl.md", []byte("{{.Nope}}"), 0644)
+
+	for _, file := range []string{"missing.tmpl.md", "broken.tmpl.md", "unknown.tmpl.md"} {
+		if _, err := render.Markdown(fs, file, render.Page{}); err == nil {
+			t.Errorf("expected error rendering %s", file)
+		}
+	}
+}
+
+func TestMarkdownRequiresStreamers(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "empty.tmpl.md", []byte(""), 0644)
+	afero.WriteFile(fs, "static.tmpl.md", []byte("# Header\n---: | --- | :--- | :---\n"), 0644)
+	afero.WriteFile(fs, "nested.tmpl.md", []byte("{{with .Active}}{{if $.Streamers}}{{len $.Streamers}}{{end}}{{end}}"), 0644)
+	afero.WriteFile(fs, "ranged.tmpl.md", []byte("{{if true}}{{range $i, $s := .Streamers}}{{$s.Name}}{{end}}{{end}}"), 0644)
+
+	for _, file := range []string{"empty.tmpl.md", "static.tmpl.md"} {
+		_, err := render.Markdown(fs, file, render.Page{})
+		if !errors.Is(err, render.ErrNoStreamers) {
+			t.Errorf("%s: Got: %v, Wanted: %v", file, err, render.ErrNoStreamers)
+		}
+	}
+	for _, file := range []string{"nested.tmpl.md", "ranged.tmpl.md"} {
+		if _, err := render.Markdown(fs, file, render.Page{}); err != nil {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+}
+
+func TestMarkdownLanguages(t *testing.T) {
+	sl := streamers.StreamerList{Streamers: []streamers.Streamer{
+		{Name: "alice", Lang: "pt-BR"},
+		{Name: "bob", Lang: "de"},
+		{Name: "carol", Lang: "pt"},
+		{Name: "dave"},
+	}}
+	page := render.NewPage(sl)
+	want := []render.Language{{Tag: "pt", Name: "português", Streamers: 2}, {Tag: "de", Name: "Deutsch", Streamers: 1}}
+	if !reflect.DeepEqual(page.Languages, want) {
+		t.Fatalf("Got: %+v, Wanted: %+v", page.Languages, want)
+	}
+
+	out, err := render.Markdown(templatesFS(t), "templates/index.tmpl.md", page, "templates/links.tmpl")
+	if err != nil {
+		t.Fatalf("Markdown failed: %v", err)
+	}
+	if !strings.Contains(string(out), "Streams by language: [português](/lang/pt) · [Deutsch](/lang/de)\n\n## List of Streams") {
+		t.Errorf("index.md is missing the language links, got:\n%s", out)
+	}
+
+	portuguese := render.NewPage(sl.InLanguage("pt"))
+	portuguese.Language = &page.Languages[0]
+	out, err = render.Markdown(templatesFS(t), "templates/index.tmpl.md", portuguese, "templates/links.tmpl")
+	if err != nil {
+		t.Fatalf("Markdown failed: %v", err)
+	}
+	for _, want := range []string{"# InfoSec Streams in português\n", "`alice`", "| pt-BR\n", "`carol`"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("the pt page is missing %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(string(out), "`bob`") || strings.Contains(string(out), "Streams by language") {
+		t.Errorf("the pt page should only list pt streamers, got:\n%s", out)
+	}
+}
+
+func TestMarkdownHistory(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	alice := streamers.Streamer{Name: "alice", ThirtyDayStats: 9}
+	bob := streamers.Streamer{Name: "bob", ThirtyDayStats: 5}
+	carol := streamers.Streamer{Name: "carol", ThirtyDayStats: 4, Added: now.Add(-24 * time.Hour)}
+	dave := streamers.Streamer{Name: "dave", ThirtyDayStats: 1, Added: now.Add(-30 * 24 * time.Hour)}
+
+	var h history.History
+	yesterday := []streamers.Streamer{{Name: "bob", ThirtyDayStats: 8}, {Name: "alice", ThirtyDayStats: 2}, dave}
+	h.Record(changes.Run{Active: yesterday}, now.Add(-24*time.Hour))
+	h.Record(changes.Run{Active: []streamers.Streamer{alice, bob, carol, dave}}, now)
+
+	page := render.NewPage(streamers.StreamerList{Streamers: []streamers.Streamer{alice, bob, carol, dave}})
+	page.GeneratedAt = now
+	page.SetHistory(h)
+
+	var movements []string
+	for _, row := range page.Streamers {
+		movements = append(movements, row.Movement())
+	}
+	if want := []string{"up", "down", "new", "down"}; !reflect.DeepEqual(movements, want) {
+		t.Errorf("Got: %v, Wanted: %v", movements, want)
+	}
+	if len(page.New) != 1 || page.New[0].Name != "carol" {
+		t.Errorf("Got: %v, Wanted only carol as new", page.New)
+	}
+
+	out, err := render.Markdown(templatesFS(t), "templates/index.tmpl.md", page, "templates/links.tmpl")
+	if err != nil {
+		t.Fatalf("Markdown failed: %v", err)
+	}
+	for _, want := range []string{"`alice` | ▲ ▁█ |", "`bob` | ▼ █▁ |", "`carol` | 🆕 |", "`dave` | ▼ ▁▁ |", "## New This Week\n\n- `carol` "} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("Got:\n%s\nWanted it to contain %q", out, want)
+		}
+	}
+}
+
+func TestMarkdownHistoryFirstRun(t *testing.T) {
+	alice := streamers.Streamer{Name: "alice", ThirtyDayStats: 9}
+	var h history.History
+	h.Record(changes.Run{Active: []streamers.Streamer{alice}}, time.Now())
+
+	page := render.NewPage(streamers.StreamerList{Streamers: []streamers.Streamer{alice}})
+	page.SetHistory(h)
+	if row := page.Streamers[0]; row.Rank != 1 || row.Movement() != "" || row.Trend != "" {
+		t.Errorf("Got: %+v, Wanted rank 1 without movement or trend", row)
+	}
+}
+
+func TestProfileMarkdown(t *testing.T) {
+	friday := time.Date(2024, 5, 10, 18, 0, 0, 0, time.UTC)
+	alice := streamers.Streamer{Name: "alice", ThirtyDayStats: 12, Lang: "de", Category: "Science & Technology", StreamLengths: []float32{1, 0, 3}, Added: friday.AddDate(0, 0, -3), Online: true}
+	alice.SetAccount(streamers.Account{Platform: streamers.Twitch, Handle: "alice"})
+
+	var h history.History
+	h.Record(changes.Run{Active: []streamers.Streamer{{Name: "alice", ThirtyDayStats: 4}}}, friday.AddDate(0, 0, -1))
+	h.Record(changes.Run{Active: []streamers.Streamer{alice}}, friday)
+
+	page := render.NewPage(streamers.StreamerList{Streamers: []streamers.Streamer{alice}})
+	page.SetHistory(h)
+	out, err := render.ProfileMarkdown(templatesFS(t), "templates/streamer.tmpl.md", render.NewProfile(page.Streamers[0], h, friday), "templates/links.tmpl")
+	if err != nil {
+		t.Fatalf("ProfileMarkdown failed: %v", err)
+	}
+	for _, want := range []string{
+		"# 🟢 alice\n\nActive, #1 by hours streamed ▁█.",
+		"- Platforms: [<i class=\"fab fa-twitch\" style=\"color:#9146FF\"></i>](https://www.twitch.tv/alice)\n",
+		"- Added: 7 May 2024\n",
+		"- Language: Deutsch\n",
+		"- Streams most in: Science & Technology\n",
+		"- Usual stream: 3 hours\n",
+		"- Usually live on: Friday\n- Usually live at: 18:00 UTC\n- Live days and hours only come from the 1 update that caught them live",
+		"2024-05-09 | 4.0 | #1\n2024-05-10 | 12.0 | #1\n",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("Got:\n%s\nWanted it to contain %q", out, want)
+		}
+	}
+}
+
+func TestLinkProfiles(t *testing.T) {
+	page := render.NewPage(streamers.StreamerList{Streamers: []streamers.Streamer{{Name: "Security_Live", Tags: []streamers.Tag{streamers.CTF}}}})
+	page.LinkProfiles("/streamers")
+	page.Chart = "/charts/hours-per-week.svg"
+	out, err := render.Markdown(templatesFS(t), "templates/index.tmpl.md", page, "templates/links.tmpl")
+	if err != nil {
+		t.Fatalf("Markdown failed: %v", err)
+	}
+	if got := strings.Count(string(out), "[`Security_Live`](/streamers/security_live)"); got != 2 {
+		t.Errorf("Got: %d links, Wanted the row and its topic linked to the profile:\n%s", got, out)
+	}
+	if !strings.Contains(string(out), "![Total hours streamed per week](/charts/hours-per-week.svg)\n\n## List of Streams") {
+		t.Errorf("Got:\n%s\nWanted the weekly chart above the list", out)
+	}
+}
@@ -0,0 +1,149 @@
+/* Package replay records HTTP responses to a cassette directory once and replays them, so whole runs can be repeated without the network. */
+package replay
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/spf13/afero"
+)
+
+// ErrNotRecorded is returned when replaying a request the cassette has no recording of.
+var ErrNotRecorded = errors.New("no recording")
+
+// Mode is whether a Transport records or replays.
+type Mode string
+
+// The modes of a Transport.
+const (
+	Replay Mode = "replay" // Answer from the cassette, never touching the network
+	Record Mode = "record" // Send requests and save their responses to the cassette
+)
+
+// Recording is a response as saved in a cassette, one file per request.
+type Recording struct {
+	Method string      `json:"method"`           // The request's method
+	URL    string      `json:"url"`              // The request's url
+	Status int         `json:"status"`           // The response's status code
+	Header http.Header `json:"header,omitempty"` // The response's Content-Type, other headers aren't kept
+	Body   string      `json:"body"`             // The response's body
+}
+
+// Transport is an http.RoundTripper that records responses to Dir, or replays them from it.
+// Request headers aren't recorded, so credentials sent in them stay out of the cassette, but a url is part
+// of its recording's file name: don't send requests with a secret in the url, like a webhook's, through it.
+type Transport struct {
+	Dir  string            // The cassette directory
+	Mode Mode              // Replay if empty
+	Next http.RoundTripper // Sends requests while recording, http.DefaultTransport if nil
+	Fs   afero.Fs          // Where the cassette lives, the OS file system if nil
+
+	mu sync.Mutex
+}
+
+// RoundTrip answers the request from the cassette, or sends and records it.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.Mode == Record {
+		return t.record(r)
+	}
+	return t.replay(r)
+}
+
+func (t *Transport) replay(r *http.Request) (*http.Response, error) {
+	file := path.Join(t.Dir, File(r.Method, r.URL.String()))
+	data, err := afero.ReadFile(t.fs(), file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("replaying %s %s: %w in %s, record it with SECINFO_RECORD=true", r.Method, r.URL, ErrNotRecorded, t.Dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rec Recording
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", file, err)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rec.Status, http.StatusText(rec.Status)),
+		StatusCode:    rec.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rec.Header.Clone(),
+		Body:          io.NopCloser(strings.NewReader(rec.Body)),
+		ContentLength: int64(len(rec.Body)),
+		Request:       r,
+	}, nil
+}
+
+func (t *Transport) record(r *http.Request) (*http.Response, error) {
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	response, err := next.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = io.NopCloser(bytes.NewReader(body))
+
+	rec := Recording{Method: r.Method, URL: r.URL.String(), Status: response.StatusCode, Body: string(body)}
+	if contentType := response.Header.Get("Content-Type"); contentType != "" {
+		rec.Header = http.Header{"Content-Type": {contentType}}
+	}
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.fs().MkdirAll(t.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := afero.WriteFile(t.fs(), path.Join(t.Dir, File(r.Method, r.URL.String())), append(data, '\n'), 0o644); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (t *Transport) fs() afero.Fs {
+	if t.Fs == nil {
+		return afero.NewOsFs()
+	}
+	return t.Fs
+}
+
+// File returns the name of the recording of a request in a cassette: the url made safe for a file name,
+// shortened, plus a hash of the method and full url so different requests never share a file.
+func File(method, url string) string {
+	sum := sha256.Sum256([]byte(method + " " + url))
+	name := url
+	if _, rest, ok := strings.Cut(name, "://"); ok {
+		name = rest
+	}
+	name = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, name)
+	if len(name) > 80 {
+		name = name[:80]
+	}
+	return name + "-" + hex.EncodeToString(sum[:4]) + ".json"
+}
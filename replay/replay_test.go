@@ -0,0 +1,87 @@
+package replay_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/infosecstreams/secinfo/replay"
+	"github.com/spf13/afero"
+)
+
+func TestRecordThenReplay(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusTeapot)
+		fmt.Fprintf(w, `{"path":%q}`, r.URL.Path)
+	}))
+	fs := afero.NewMemMapFs()
+
+	recorder := &http.Client{Transport: &replay.Transport{Dir: "cassette", Mode: replay.Record, Fs: fs}}
+	request, _ := http.NewRequest("GET", server.URL+"/api/stats", nil)
+	request.Header.Set("Authorization", "Bearer token")
+	if body := get(t, recorder, request); body != `{"path":"/api/stats"}` {
+		t.Errorf("Got: %s, Wanted the live response while recording", body)
+	}
+	server.Close()
+
+	file := "cassette/" + replay.File("GET", server.URL+"/api/stats")
+	data, err := afero.ReadFile(fs, file)
+	if err != nil {
+		t.Fatalf("Got: %v, Wanted a recording in %s", err, file)
+	}
+	for _, secret := range []string{"secret", "Bearer"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("Got: %s, Wanted %q left out of the recording", data, secret)
+		}
+	}
+
+	player := &http.Client{Transport: &replay.Transport{Dir: "cassette", Fs: fs}}
+	response, err := player.Get(server.URL + "/api/stats")
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	if response.StatusCode != http.StatusTeapot || response.Header.Get("Content-Type") != "application/json" || string(body) != `{"path":"/api/stats"}` {
+		t.Errorf("Got: %d %v %s, Wanted the recorded response", response.StatusCode, response.Header, body)
+	}
+	if requests != 1 {
+		t.Errorf("Got: %d requests, Wanted only the recorded one", requests)
+	}
+
+	if _, err := player.Get(server.URL + "/api/other"); !errors.Is(err, replay.ErrNotRecorded) {
+		t.Errorf("Got: %v, Wanted: %v", err, replay.ErrNotRecorded)
+	}
+}
+
+func TestFile(t *testing.T) {
+	got := replay.File("GET", "https://sullygnome.com/channel/Alice/30/activitystats")
+	if want := "sullygnome.com_channel_Alice_30_activitystats-"; !strings.HasPrefix(got, want) {
+		t.Errorf("Got: %s, Wanted it to start with %s", got, want)
+	}
+	if replay.File("GET", "https://example.com/a?b") == replay.File("GET", "https://example.com/a_b") {
+		t.Errorf("different urls should be recorded in different files")
+	}
+	if replay.File("GET", "https://example.com/") == replay.File("POST", "https://example.com/") {
+		t.Errorf("different methods should be recorded in different files")
+	}
+}
+
+func get(t *testing.T, client *http.Client, request *http.Request) string {
+	t.Helper()
+	response, err := client.Do(request)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(response.Body)
+	return string(body)
+}
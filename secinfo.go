@@ -1,157 +1,237 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"net/http"
 	"os"
+	"os/signal"
 	"strings"
-
-	"github.com/infosecstreams/secinfo/streamers"
+	"syscall"
+	"time"
+
+	"github.com/infosecstreams/secinfo/changes"
+	"github.com/infosecstreams/secinfo/config"
+	"github.com/infosecstreams/secinfo/notify"
+	"github.com/infosecstreams/secinfo/output"
+	"github.com/infosecstreams/secinfo/pipeline"
+	"github.com/infosecstreams/secinfo/summary"
 	"github.com/spf13/afero"
 )
 
+// exitTooManyFailures is the exit status of a run that wrote nothing, or kept stats from the last run,
+// because too many lookups failed. A workflow can tell it apart to skip committing a wiped list.
+const exitTooManyFailures = 3
+
 func main() {
-	active := streamers.StreamerList{}
-	inactive := streamers.StreamerList{}
-
-	// Check environ SECINFO_TEST exists
-	if os.Getenv("SECINFO_TEST") == "" {
-		f, err := streamers.OpenCSV("streamers.csv")
-		if err != nil {
-			fmt.Printf("Error reading csv: %s\n", err)
-			os.Exit(1)
-		}
-		defer f.Close()
+	var err error
+	if len(os.Args) > 1 {
+		err = command(os.Args[1:])
+	} else {
+		err = run()
+	}
+	if err != nil {
+		fmt.Printf("Error %s\n", err)
+		os.Exit(exitCode(err))
+	}
+}
 
-		activeFromFile, err := streamers.ParseStreamers(f)
-		if err != nil {
-			fmt.Println(err)
-		}
+// exitCode returns the exit status of a run that returned err.
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, pipeline.ErrTooManyFailures):
+		return exitTooManyFailures
+	default:
+		return 1
+	}
+}
 
-		inactiveFromFile := streamers.StreamerList{}
-		inactiveFile, err := streamers.OpenCSV("inactive_streamers.csv")
-		if err == nil {
-			defer inactiveFile.Close()
-			inactiveFromFile, err = streamers.ParseStreamers(inactiveFile)
-			if err != nil {
-				fmt.Println(err)
-			}
-			for i := range inactiveFromFile.Streamers {
-				inactiveFromFile.Streamers[i].WasInactive = true
-			}
-		} else if !os.IsNotExist(err) {
-			fmt.Printf("Error reading inactive csv: %s\n", err)
+// run updates every configured streamer list and renders its pages, then reports and announces what changed.
+func run() error {
+	appFS := afero.NewOsFs()
+	cfg, err := config.Load(appFS, os.Getenv("SECINFO_CONFIG"))
+	if err != nil {
+		return err
+	}
+	// Check everything "secinfo config validate" does before anything is fetched
+	if err := cfg.Validate(appFS); err != nil {
+		return fmt.Errorf("invalid config:\n%w", err)
+	}
+	providers, err := cfg.StatsProviders()
+	if err != nil {
+		return err
+	}
+
+	// Set up notifications first so a typo in their settings fails before anything is fetched
+	channels, err := notifyChannels(cfg.WindowDays, cfg.NotifyClient())
+	if err != nil {
+		return err
+	}
+
+	// Stop without writing anything on SIGINT or SIGTERM, a second one kills the run right away
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	result, err := pipeline.Run(ctx, cfg, appFS, providers)
+	if err != nil {
+		// A run stopped by failed lookups still explains them in the job summary
+		for _, r := range result.Lists {
+			logReport(r.Report)
+			appendJobSummary(appFS, r.Report)
 		}
+		return err
+	}
 
-		// Only process active streamers from streamers.csv for stats
-		// Inactive streamers are kept as-is without checking stats
-		for _, streamer := range activeFromFile.Streamers {
-			// Populate the streamer struct with the data SullyGnome has
-			streamer.GetUID()
-			if streamer.SullyGnomeID == "" {
-				fmt.Printf("streamer has no SullyGnomeID: %s\n", streamer.Name)
-				inactive.Streamers = append(inactive.Streamers, streamer)
-				continue
-			}
-			streamer.GetStats()
+	for _, r := range result.Lists {
+		logReport(r.Report)
+		appendJobSummary(appFS, r.Report)
 
-			// Append the streamer to the new streamerList
-			if streamer.ThirtyDayStats > 0 {
-				active.Streamers = append(active.Streamers, streamer)
-			} else {
-				inactive.Streamers = append(inactive.Streamers, streamer)
+		// Announce streamers going live and the daily digest, a failed send doesn't fail the run.
+		// Test runs reuse the last run's lists, so they announce nothing and leave the state alone.
+		if len(channels) > 0 && !cfg.Test {
+			if err := sendNotifications(ctx, appFS, r.List, channels, r.Current, r.Events, r.Report.GeneratedAt); err != nil {
+				fmt.Printf("Error sending notifications: %s\n", err)
 			}
 		}
+	}
 
-		// Add all inactive streamers to the inactive list WITHOUT checking stats
-		// (they remain inactive until manually moved back to streamers.csv)
-		for _, streamer := range inactiveFromFile.Streamers {
-			inactive.Streamers = append(inactive.Streamers, streamer)
-		}
-	} else {
-		// Read test.json into active struct
-		f, _ := ioutil.ReadFile("active.json")
-		_ = json.Unmarshal([]byte(f), &active)
-
-		// Read inactive.json into inactive struct
-		f, _ = ioutil.ReadFile("inactive.json")
-		_ = json.Unmarshal([]byte(f), &inactive)
-	}
-
-	// Call sort on the active streamer list
-	active.Sort()   // Sort active by ThirtyDayStats (descending)
-	inactive.Sort() // Sort inactive by ThirtyDayStats for JSON
-
-	// Write the active struct to active.json if SECINFO_TEST is not set so latest data is available
-	if os.Getenv("SECINFO_TEST") == "" {
-		appFS := afero.NewOsFs()
-		j, _ := json.Marshal(active)
-		ioutil.WriteFile("active.json", j, 0644)
-
-		// write inactive.json
-		j, _ = json.Marshal(inactive)
-		ioutil.WriteFile("inactive.json", j, 0644)
-
-		// Write updated CSV files, sorted by name for human readability
-		activeCSVList := streamers.StreamerList{Streamers: active.Streamers}
-		if err := activeCSVList.WriteCSVWithFS(appFS, "streamers.csv"); err != nil {
-			fmt.Printf("Error writing streamers.csv: %s\n", err)
-			os.Exit(1)
-		}
-		inactiveCSVList := streamers.StreamerList{Streamers: inactive.Streamers}
-		if err := inactiveCSVList.WriteCSVWithFS(appFS, "inactive_streamers.csv"); err != nil {
-			fmt.Printf("Error writing inactive_streamers.csv: %s\n", err)
-			os.Exit(1)
+	// The outputs are written, but the workflow should know they hold old stats
+	for _, r := range result.Lists {
+		if r.Report.KeptPrevious {
+			return fmt.Errorf("%w: %d streamers kept their stats from the last run", pipeline.ErrTooManyFailures, len(r.Report.Failures))
 		}
 	}
+	return nil
+}
+
+// logReport prints the failed lookups and warnings of a list's run.
+func logReport(report summary.Report) {
+	prefix := ""
+	if report.List != "" {
+		prefix = report.List + ": "
+	}
+	for _, f := range report.Failures {
+		fmt.Printf("%sError fetching stats for %s: %s\n", prefix, f.Name, f.Err)
+	}
+	if report.KeptPrevious {
+		fmt.Printf("%s%d lookups failed, keeping their stats from the last run\n", prefix, len(report.Failures))
+	}
+	for _, w := range report.Warnings {
+		fmt.Printf("%sWarning %s\n", prefix, w)
+	}
+}
 
-	// Markdown time!
-	// Read existing index.md into a string
-	indexMd, _ := ioutil.ReadFile("index.md")
-	indexStr := string(indexMd)
-
-	// Read index.tmpl.md into a string
-	indexMdTemplate, _ := ioutil.ReadFile("templates/index.tmpl.md")
-	// Find  '---: | --- | :--- | :---' and append each streamer in streamerist using ReturnMarkdownLine()
-	heading := "---: | --- | :--- | :---\n"
-	i := strings.Index(string(indexMdTemplate), heading) + len(heading)
-	// Print line from the i indexMD
-	newMd := string(indexMdTemplate[:i])
-	for _, streamer := range active.Streamers {
-		s, err := streamer.ReturnMarkdownLine(streamer.OnlineNow(indexStr))
-		if err != nil {
-			fmt.Println(err)
+// appendJobSummary adds report to the job summary when running in GitHub Actions.
+func appendJobSummary(fileSystem afero.Fs, report summary.Report) {
+	if path := os.Getenv("GITHUB_STEP_SUMMARY"); path != "" {
+		if err := summary.Append(fileSystem, path, report.Markdown()); err != nil {
+			fmt.Printf("Error writing job summary: %s\n", err)
 		}
-		newMd += s
-	}
-	newMd += string(indexMdTemplate[i:])
-	// Write index.md
-	ioutil.WriteFile("./index.md", []byte(newMd), 0644)
-
-	// Clear markdown string
-	newMd = ""
-	// Read inactive.tmpl.md into a string
-	inactiveMD, _ := ioutil.ReadFile("templates/inactive.tmpl.md")
-	// Fine '--: | --- | :--- | :---' and append each streamer in inactive using ReturnMarkdownLine()
-	heading = "--: | ---\n"
-	i = strings.Index(string(inactiveMD), heading) + len(heading)
-	// Print line to the i indexMD
-	newMd = string(inactiveMD[:i])
-
-	// Sort inactive streamers by name for alphabetical display in markdown
-	inactiveByName := streamers.StreamerList{Streamers: inactive.Streamers}
-	inactiveByName.SortByName()
-
-	for _, streamer := range inactiveByName.Streamers {
-		s, err := streamer.ReturnMarkdownLine(false) // Sorry inactive can't be online
-		if err != nil {
-			fmt.Println(err)
+	}
+}
+
+// sendNotifications sends a list's notifications, named after the list, and saves what was sent to its state file.
+func sendNotifications(ctx context.Context, fileSystem afero.Fs, list config.List, channels []notify.Channel, current changes.Run, events []changes.Event, now time.Time) error {
+	st, err := notify.LoadState(fileSystem, list.Paths.NotifyState)
+	if err != nil {
+		return err
+	}
+	named := make([]notify.Channel, len(channels))
+	for i, c := range channels {
+		c.Title, c.SiteURL = list.Title, list.SiteURL
+		named[i] = c
+	}
+	sendErr := notify.Process(ctx, named, st, current, events, now)
+
+	data, err := st.JSON()
+	if err != nil {
+		return errors.Join(sendErr, err)
+	}
+	var batch output.Batch
+	batch.Add(list.Paths.NotifyState, data)
+	return errors.Join(sendErr, batch.Commit(fileSystem))
+}
+
+// notifyChannels configures a notification channel for every service with credentials in the environment,
+// sending with client.
+// <PREFIX>_EVENTS and <PREFIX>_DIGEST pick the events sent right away and in the daily digest (comma-separated,
+// or "none"), and <PREFIX>_TEMPLATE_<EVENT> replaces the template of an event, e.g. SLACK_TEMPLATE_LIVE.
+func notifyChannels(windowDays int, client *http.Client) ([]notify.Channel, error) {
+	var channels []notify.Channel
+	add := func(name, prefix string, n notify.Notifier) {
+		c := notify.Channel{Name: name, Notifier: n, Templates: map[changes.Kind]string{}, WindowDays: windowDays}
+		c.Events = kindsFromEnv(prefix + "_EVENTS")
+		c.Digest = kindsFromEnv(prefix + "_DIGEST")
+		for _, kind := range append(changes.Kinds, notify.KindDigest) {
+			if text := os.Getenv(prefix + "_TEMPLATE_" + strings.ToUpper(string(kind))); text != "" {
+				c.Templates[kind] = text
+			}
 		}
-		newMd += s
+		channels = append(channels, c)
+	}
+
+	if url := os.Getenv("DISCORD_WEBHOOK_URL"); url != "" {
+		add("discord", "DISCORD", notify.Discord{WebhookURL: url, Client: client})
+	}
+	if url := os.Getenv("SLACK_WEBHOOK_URL"); url != "" {
+		add("slack", "SLACK", notify.Slack{WebhookURL: url, Client: client})
+	}
+	if server := os.Getenv("MATRIX_HOMESERVER"); server != "" {
+		add("matrix", "MATRIX", notify.Matrix{Homeserver: server, AccessToken: os.Getenv("MATRIX_ACCESS_TOKEN"), RoomID: os.Getenv("MATRIX_ROOM_ID"), Client: client})
+	}
+	if server := os.Getenv("MASTODON_SERVER"); server != "" {
+		add("mastodon", "MASTODON", notify.Mastodon{Server: server, AccessToken: os.Getenv("MASTODON_ACCESS_TOKEN"), Visibility: os.Getenv("MASTODON_VISIBILITY"), Client: client})
+	}
+
+	var errs []error
+	for _, c := range channels {
+		errs = append(errs, c.Validate())
+	}
+	return channels, errors.Join(errs...)
+}
+
+// kindsFromEnv parses a comma-separated list of event kinds. Unset is nil, the channel's default, and "none" is no events.
+func kindsFromEnv(key string) []changes.Kind {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	kinds := []changes.Kind{}
+	if value == "none" {
+		return kinds
+	}
+	for _, kind := range strings.Split(value, ",") {
+		kinds = append(kinds, changes.Kind(strings.TrimSpace(kind)))
+	}
+	return kinds
+}
+
+// command runs a subcommand, so far only "config validate [file]".
+func command(args []string) error {
+	if len(args) < 2 || args[0] != "config" || args[1] != "validate" || len(args) > 3 {
+		return fmt.Errorf("unknown command %q, usage: secinfo [config validate [file]]", strings.Join(args, " "))
+	}
+	path := os.Getenv("SECINFO_CONFIG")
+	if len(args) == 3 {
+		path = args[2]
+	}
+
+	appFS := afero.NewOsFs()
+	cfg, err := config.Load(appFS, path)
+	if err != nil {
+		return err
+	}
+	_, notifyErr := notifyChannels(cfg.WindowDays, cfg.NotifyClient())
+	if err := errors.Join(cfg.Validate(appFS), notifyErr); err != nil {
+		return fmt.Errorf("invalid config:\n%w", err)
 	}
-	// Print line from the i indexMD
-	newMd += string(inactiveMD[i:])
-	// Write inactive.md
-	ioutil.WriteFile("./inactive.md", []byte(newMd), 0644)
+	fmt.Println("config ok")
+	return nil
 }
@@ -2,17 +2,24 @@ package main
 
 import (
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/infosecstreams/secinfo/notify"
 	"github.com/infosecstreams/secinfo/streamers"
+	"github.com/infosecstreams/secinfo/sullygnometest"
 )
 
 const (
-	indexTemplate    = "Header\n---: | --- | :--- | :---\nFooter\n"
-	inactiveTemplate = "Header\n--: | ---\nFooter\n"
+	indexTemplate    = "Header\n---: | --- | :--- | :---\n{{range .Streamers}}{{if .Online}}🟢{{end}} | `{{.Name}}` | {{template \"links\" .}}\n{{end}}Footer\n"
+	inactiveTemplate = "Header\n--: | ---\n{{range .Streamers}}`{{.Name}}` | {{template \"links\" .}}\n{{end}}Footer\n"
+	linksTemplate    = "{{define \"links\"}}{{range .Accounts}}[{{.Platform}}]({{.URL}}){{end}}{{end}}"
 )
 
 func TestMainWithTestEnvOrdersOutput(t *testing.T) {
@@ -49,6 +56,67 @@ func TestMainWithTestEnvOrdersOutput(t *testing.T) {
 	})
 }
 
+func TestRunRendersLanguagePages(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		writeTemplates(t, dir)
+		writeJSON(t, filepath.Join(dir, "active.json"), streamers.StreamerList{Streamers: []streamers.Streamer{
+			{Name: "Alpha", ThirtyDayStats: 2},
+			{Name: "bravo", ThirtyDayStats: 10, Lang: "es"},
+		}})
+		t.Setenv("SECINFO_TEST", "1")
+
+		if err := run(); err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+		es := readFile(t, filepath.Join(dir, "lang", "es.md"))
+		assertOrder(t, es, []string{"`bravo`"})
+		if strings.Contains(es, "Alpha") {
+			t.Errorf("Got: %s, Wanted only the Spanish streamers", es)
+		}
+	})
+}
+
+func TestRunAppendsJobSummary(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		writeTemplates(t, dir)
+		writeJSON(t, filepath.Join(dir, "active.json"), streamers.StreamerList{Streamers: []streamers.Streamer{
+			{Name: "bravo", ThirtyDayStats: 10},
+			{Name: "Charlie", ThirtyDayStats: 5},
+		}})
+		writeJSON(t, filepath.Join(dir, "inactive.json"), streamers.StreamerList{Streamers: []streamers.Streamer{{Name: "Zulu"}}})
+		t.Setenv("SECINFO_TEST", "1")
+		t.Setenv("GITHUB_STEP_SUMMARY", filepath.Join(dir, "step_summary.md"))
+		writeFile(t, filepath.Join(dir, "step_summary.md"), "from an earlier step\n")
+
+		if err := run(); err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+		stepSummary := readFile(t, filepath.Join(dir, "step_summary.md"))
+		if !strings.HasPrefix(stepSummary, "from an earlier step\n## secinfo run") || !strings.Contains(stepSummary, "| 2 | 1 | 15.0 |") {
+			t.Errorf("Got: %q, Wanted the report appended to the job summary", stepSummary)
+		}
+	})
+}
+
+func TestRunPublishes(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		writeTemplates(t, dir)
+		writeFile(t, filepath.Join(dir, "streamers.csv"), "bravo,\n")
+		writeJSON(t, filepath.Join(dir, "active.json"), streamers.StreamerList{Streamers: []streamers.Streamer{{Name: "alpha", ThirtyDayStats: 2}}})
+		fakeSullyGnome(t, sullygnometest.Channel{Name: "bravo", StreamLengths: []float32{0, 5}})
+		t.Setenv("SECINFO_PR_BODY", "pr_body.md")
+
+		if err := run(); err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+		for _, file := range []string{"atom.xml", "api/v1/streamers.json", "export/twitch.m3u", "pr_body.md"} {
+			if got := readFile(t, filepath.Join(dir, file)); !strings.Contains(got, "bravo") {
+				t.Errorf("Got: %q, Wanted bravo in %s", got, file)
+			}
+		}
+	})
+}
+
 func TestMainWithoutTestEnvEmptyCsv(t *testing.T) {
 	withTempDir(t, func(dir string) {
 		writeTemplates(t, dir)
@@ -63,15 +131,395 @@ func TestMainWithoutTestEnvEmptyCsv(t *testing.T) {
 		indexOut := readFile(t, filepath.Join(dir, "index.md"))
 		inactiveOut := readFile(t, filepath.Join(dir, "inactive.md"))
 
-		if indexOut != indexTemplate {
+		if indexOut != "Header\n---: | --- | :--- | :---\nFooter\n" {
 			t.Fatalf("index.md should match template when empty: %q", indexOut)
 		}
-		if inactiveOut != inactiveTemplate {
+		if inactiveOut != "Header\n--: | ---\nFooter\n" {
 			t.Fatalf("inactive.md should match template when empty: %q", inactiveOut)
 		}
+		if history := readFile(t, filepath.Join(dir, "history.json")); !strings.Contains(history, `"streamers":{}`) {
+			t.Errorf("Got: %s, Wanted an empty history", history)
+		}
+	})
+}
+
+func TestRunLeavesPagesWhenTemplateBroken(t *testing.T) {
+	for name, mutate := range map[string]func(dir string){
+		"missing index template": func(dir string) {
+			os.Remove(filepath.Join(dir, "templates", "index.tmpl.md"))
+		},
+		"index template without table": func(dir string) {
+			writeFile(t, filepath.Join(dir, "templates", "index.tmpl.md"), "Header\n---: | --- | :---\nFooter\n")
+		},
+		"broken inactive template": func(dir string) {
+			writeFile(t, filepath.Join(dir, "templates", "inactive.tmpl.md"), "{{range .Streamers}}")
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			withTempDir(t, func(dir string) {
+				writeTemplates(t, dir)
+				mutate(dir)
+				writeFile(t, filepath.Join(dir, "index.md"), "existing index\n")
+				writeFile(t, filepath.Join(dir, "inactive.md"), "existing inactive\n")
+				writeJSON(t, filepath.Join(dir, "active.json"), streamers.StreamerList{})
+				writeJSON(t, filepath.Join(dir, "inactive.json"), streamers.StreamerList{})
+
+				t.Setenv("SECINFO_TEST", "1")
+
+				if err := run(); err == nil {
+					t.Fatalf("run should fail")
+				}
+				if got := readFile(t, filepath.Join(dir, "index.md")); got != "existing index\n" {
+					t.Fatalf("index.md was modified: %q", got)
+				}
+				if got := readFile(t, filepath.Join(dir, "inactive.md")); got != "existing inactive\n" {
+					t.Fatalf("inactive.md was modified: %q", got)
+				}
+			})
+		})
+	}
+}
+
+func TestNotifyChannelsFromEnv(t *testing.T) {
+	t.Setenv("SLACK_WEBHOOK_URL", "https://hooks.slack.com/services/x")
+	t.Setenv("SLACK_EVENTS", "live, added")
+	t.Setenv("SLACK_DIGEST", "none")
+	t.Setenv("SLACK_TEMPLATE_LIVE", "{{.Name}} is up")
+	t.Setenv("MASTODON_SERVER", "https://infosec.exchange")
+
+	channels, err := notifyChannels(0, &http.Client{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("notifyChannels failed: %v", err)
+	}
+	if len(channels) != 2 || channels[0].Name != "slack" || channels[1].Name != "mastodon" {
+		t.Fatalf("Got: %+v", channels)
+	}
+	slack := channels[0]
+	if len(slack.Events) != 2 || slack.Events[1] != "added" || slack.Digest == nil || len(slack.Digest) != 0 {
+		t.Errorf("Got events %q, digest %q", slack.Events, slack.Digest)
+	}
+	if slack.Templates["live"] != "{{.Name}} is up" {
+		t.Errorf("Got: %q", slack.Templates)
+	}
+	if client := channels[1].Notifier.(notify.Mastodon).Client; client == nil || client.Timeout != time.Second {
+		t.Errorf("Got: %v, Wanted the notifiers to send with the given client", client)
+	}
+	if channels[1].Events != nil {
+		t.Errorf("unset events should use the channel's default, Got: %q", channels[1].Events)
+	}
+
+	t.Setenv("SLACK_EVENTS", "party")
+	if _, err := notifyChannels(0, nil); err == nil {
+		t.Fatalf("unknown events should fail")
+	}
+}
+
+func TestRunTestModeSendsNoNotifications(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		writeTemplates(t, dir)
+		writeJSON(t, filepath.Join(dir, "active.json"), streamers.StreamerList{Streamers: []streamers.Streamer{{Name: "bravo", ThirtyDayStats: 10, Online: true}}})
+		var requests atomic.Int32
+		hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { requests.Add(1) }))
+		defer hook.Close()
+		t.Setenv("SLACK_WEBHOOK_URL", hook.URL)
+		t.Setenv("SLACK_EVENTS", "live,added,promoted,demoted,removed")
+		t.Setenv("SECINFO_TEST", "1")
+
+		if err := run(); err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+		if n := requests.Load(); n != 0 {
+			t.Errorf("Got: %d notifications, Wanted: 0 in test mode", n)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "notify_state.json")); !os.IsNotExist(err) {
+			t.Errorf("notify_state.json written in test mode, Got: %v", err)
+		}
 	})
 }
 
+func TestRunValidatesConfig(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		writeTemplates(t, dir)
+		writeFile(t, filepath.Join(dir, "secinfo.json"), `{"window_days": 31}`)
+		t.Setenv("SECINFO_TEST", "1")
+
+		if err := run(); err == nil || !strings.Contains(err.Error(), "window_days") {
+			t.Errorf("Got: %v, Wanted the run to stop on an invalid config", err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "index.md")); !os.IsNotExist(err) {
+			t.Errorf("index.md written despite the invalid config, Got: %v", err)
+		}
+	})
+}
+
+func TestRunUsesConfigPaths(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		writeTemplates(t, dir)
+		writeFile(t, filepath.Join(dir, "secinfo.json"), `{"paths": {"index": "docs/index.md", "active_json": "state/active.json"}}`)
+		writeJSON(t, filepath.Join(dir, "state", "active.json"), streamers.StreamerList{Streamers: []streamers.Streamer{{Name: "bravo", ThirtyDayStats: 10}}})
+		t.Setenv("SECINFO_TEST", "1")
+
+		if err := run(); err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+		assertOrder(t, readFile(t, filepath.Join(dir, "docs", "index.md")), []string{"`bravo`"})
+		if _, err := os.Stat(filepath.Join(dir, "index.md")); !os.IsNotExist(err) {
+			t.Errorf("index.md written despite the config, Got: %v", err)
+		}
+	})
+}
+
+func TestRunProcessesEveryList(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		writeTemplates(t, dir)
+		writeFile(t, filepath.Join(dir, "secinfo.json"), `{"lists": [
+			{"name": "infosec", "paths": {"index_template": "templates/index.tmpl.md", "inactive_template": "templates/inactive.tmpl.md", "links_template": "templates/links.tmpl"}},
+			{"name": "ctf", "title": "CTF Streams", "paths": {"index_template": "templates/index.tmpl.md", "inactive_template": "templates/inactive.tmpl.md", "links_template": "templates/links.tmpl"}}
+		]}`)
+		for _, list := range []string{"infosec", "ctf"} {
+			if err := os.MkdirAll(filepath.Join(dir, list), 0755); err != nil {
+				t.Fatalf("mkdir %s failed: %v", list, err)
+			}
+		}
+		writeFile(t, filepath.Join(dir, "infosec", "streamers.csv"), "bravo,\n")
+		writeFile(t, filepath.Join(dir, "ctf", "streamers.csv"), "flagz,\n")
+		fakeSullyGnome(t, sullygnometest.Channel{Name: "bravo", StreamLengths: []float32{0, 5}}, sullygnometest.Channel{Name: "flagz", StreamLengths: []float32{3}})
+
+		if err := run(); err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+		assertOrder(t, readFile(t, filepath.Join(dir, "infosec", "index.md")), []string{"`bravo`"})
+		ctf := readFile(t, filepath.Join(dir, "ctf", "index.md"))
+		assertOrder(t, ctf, []string{"`flagz`"})
+		if strings.Contains(ctf, "bravo") {
+			t.Errorf("Got: %s, Wanted only the ctf list", ctf)
+		}
+		if atom := readFile(t, filepath.Join(dir, "ctf", "atom.xml")); !strings.Contains(atom, "CTF Streams") {
+			t.Errorf("Got: %s, Wanted the ctf list's title", atom)
+		}
+	})
+}
+
+func TestRunSortsEachOutput(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		writeTemplates(t, dir)
+		writeFile(t, filepath.Join(dir, "secinfo.json"), `{"sort": {"index": "name"}}`)
+		writeFile(t, filepath.Join(dir, "streamers.csv"), "charlie,\nalpha,\nbravo,\n")
+		fakeSullyGnome(t,
+			sullygnometest.Channel{Name: "charlie", StreamLengths: []float32{3}},
+			sullygnometest.Channel{Name: "alpha", StreamLengths: []float32{2}},
+			sullygnometest.Channel{Name: "bravo", StreamLengths: []float32{0, 5}},
+		)
+
+		if err := run(); err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+		assertOrder(t, readFile(t, filepath.Join(dir, "index.md")), []string{"`alpha`", "`bravo`", "`charlie`"})
+		assertOrder(t, readFile(t, filepath.Join(dir, "api", "v1", "streamers.json")), []string{`"bravo"`, `"charlie"`, `"alpha"`})
+	})
+}
+
+func TestRunRendersProfiles(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		writeTemplates(t, dir)
+		writeFile(t, filepath.Join(dir, "templates", "streamer.tmpl.md"), "{{.Name}} #{{.Rank}} {{len .Points}}\n")
+		writeFile(t, filepath.Join(dir, "secinfo.json"), `{"paths": {"profiles": "people"}}`)
+		writeFile(t, filepath.Join(dir, "streamers.csv"), "Bravo,\n")
+		writeFile(t, filepath.Join(dir, "inactive_streamers.csv"), "zulu,\n")
+		fakeSullyGnome(t, sullygnometest.Channel{Name: "Bravo", StreamLengths: []float32{0, 5}})
+
+		if err := run(); err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+		if got := readFile(t, filepath.Join(dir, "people", "bravo.md")); got != "Bravo #1 1\n" {
+			t.Errorf("Got: %q, Wanted: %q", got, "Bravo #1 1\n")
+		}
+		if got := readFile(t, filepath.Join(dir, "people", "zulu.md")); got != "zulu #0 1\n" {
+			t.Errorf("Got: %q, Wanted: %q", got, "zulu #0 1\n")
+		}
+		for _, svg := range []string{"hours-per-week.svg", "streamers/bravo.svg", "streamers/zulu.svg"} {
+			if got := readFile(t, filepath.Join(dir, "charts", svg)); !strings.HasPrefix(got, "<svg ") {
+				t.Errorf("Got: %q, Wanted an SVG chart in charts/%s", got, svg)
+			}
+		}
+	})
+}
+
+// TestRunReplaysCassette runs the whole pipeline against testdata/cassette. The cassette is a synthetic fixture,
+// hand-written in the recording format for made up channels, rather than a recording of SullyGnome.
+func TestRunReplaysCassette(t *testing.T) {
+	cassette, err := filepath.Abs(filepath.Join("testdata", "cassette"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	withTempDir(t, func(dir string) {
+		writeTemplates(t, dir)
+		writeFile(t, filepath.Join(dir, "streamers.csv"), "Alice,\nbob,\ncarol,\n")
+		summaryPath := filepath.Join(dir, "summary.md")
+		t.Setenv("SECINFO_TEST", "")
+		t.Setenv("SECINFO_CASSETTE", cassette)
+		t.Setenv("GITHUB_STEP_SUMMARY", summaryPath)
+
+		if err := run(); err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+		var active, inactive streamers.StreamerList
+		readJSON(t, filepath.Join(dir, "active.json"), &active)
+		readJSON(t, filepath.Join(dir, "inactive.json"), &inactive)
+		if len(active.Streamers) != 1 || active.Streamers[0].Name != "Alice" || active.Streamers[0].ThirtyDayStats != 11 || active.Streamers[0].SullyGnomeID != "101" {
+			t.Errorf("Got: %+v, Wanted Alice active with the fixture's 11 hours", active.Streamers)
+		}
+		if len(inactive.Streamers) != 2 {
+			t.Errorf("Got: %+v, Wanted bob and carol inactive", inactive.Streamers)
+		}
+		assertOrder(t, readFile(t, filepath.Join(dir, "index.md")), []string{"`Alice`"})
+		if summary := readFile(t, summaryPath); !strings.Contains(summary, "carol") {
+			t.Errorf("Got: %s, Wanted carol's failed lookup in the summary", summary)
+		}
+	})
+}
+
+func TestRunRecordsNoNotifications(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		writeTemplates(t, dir)
+		writeFile(t, filepath.Join(dir, "streamers.csv"), "bravo,\n")
+		writeJSON(t, filepath.Join(dir, "active.json"), streamers.StreamerList{Streamers: []streamers.Streamer{{Name: "alpha", ThirtyDayStats: 2}}})
+		fakeSullyGnome(t, sullygnometest.Channel{Name: "bravo", StreamLengths: []float32{0, 5}})
+		var requests atomic.Int32
+		hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer hook.Close()
+		cassette := filepath.Join(dir, "cassette")
+		t.Setenv("SECINFO_CASSETTE", cassette)
+		t.Setenv("SECINFO_RECORD", "true")
+		t.Setenv("DISCORD_WEBHOOK_URL", hook.URL+"/api/webhooks/1/webhooksecret")
+		t.Setenv("DISCORD_EVENTS", "added")
+
+		if err := run(); err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+		if n := requests.Load(); n == 0 {
+			t.Errorf("Got: %d notifications, Wanted bravo being added sent to the webhook", n)
+		}
+		entries, err := os.ReadDir(cassette)
+		if err != nil || len(entries) == 0 {
+			t.Fatalf("Got: %v, Wanted the lookups recorded", err)
+		}
+		for _, entry := range entries {
+			if strings.Contains(entry.Name(), "webhook") || strings.Contains(readFile(t, filepath.Join(cassette, entry.Name())), "webhooksecret") {
+				t.Errorf("Got: %s, Wanted the webhook kept out of the cassette", entry.Name())
+			}
+		}
+	})
+}
+
+func TestRunAgainstFakeSullyGnome(t *testing.T) {
+	server := sullygnometest.NewServer(
+		sullygnometest.Channel{Name: "Alice", StreamLengths: []float32{2, 3, 1}},
+		sullygnometest.Channel{Name: "bob", StreamLengths: []float32{0, 2}, Failure: sullygnometest.Slow},
+		sullygnometest.Channel{Name: "carol", Failure: sullygnometest.NotFound},
+		sullygnometest.Channel{Name: "dave", Failure: sullygnometest.ServerError},
+		sullygnometest.Channel{Name: "eve", Failure: sullygnometest.MalformedHTML},
+		sullygnometest.Channel{Name: "frank", Failure: sullygnometest.EmptyDatasets},
+	)
+	defer server.Close()
+	server.Delay = 50 * time.Millisecond
+
+	withTempDir(t, func(dir string) {
+		writeTemplates(t, dir)
+		writeFile(t, filepath.Join(dir, "streamers.csv"), "Alice,\nbob,\ncarol,\ndave,\neve,\nfrank,\n")
+		summaryPath := filepath.Join(dir, "summary.md")
+		t.Setenv("SECINFO_TEST", "")
+		t.Setenv("SECINFO_SULLYGNOME_URL", server.URL)
+		t.Setenv("GITHUB_STEP_SUMMARY", summaryPath)
+		t.Setenv("SECINFO_MAX_FAILURES", "100") // Most of the channels fail on purpose
+
+		if err := run(); err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+		var active, inactive streamers.StreamerList
+		readJSON(t, filepath.Join(dir, "active.json"), &active)
+		readJSON(t, filepath.Join(dir, "inactive.json"), &inactive)
+		if len(active.Streamers) != 2 || active.Streamers[0].ThirtyDayStats != 11 || active.Streamers[1].ThirtyDayStats != 4 {
+			t.Errorf("Got: %+v, Wanted Alice with 11 hours and the slow bob with 4", active.Streamers)
+		}
+		if len(inactive.Streamers) != 4 {
+			t.Errorf("Got: %+v, Wanted the 4 failing channels inactive", inactive.Streamers)
+		}
+		assertOrder(t, readFile(t, filepath.Join(dir, "index.md")), []string{"`Alice`", "`bob`"})
+		summary := readFile(t, summaryPath)
+		for _, name := range []string{"carol", "dave", "eve", "frank"} {
+			if !strings.Contains(summary, name) {
+				t.Errorf("Got: %s, Wanted %s's failed lookup in the summary", summary, name)
+			}
+		}
+	})
+}
+
+func TestRunTimeoutExitStatus(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		writeTemplates(t, dir)
+		writeFile(t, filepath.Join(dir, "streamers.csv"), "bob,\n")
+		fakeSullyGnome(t, sullygnometest.Channel{Name: "bob", StreamLengths: []float32{0, 2}, Failure: sullygnometest.Slow})
+		t.Setenv("SECINFO_TIMEOUT", "100ms")
+
+		if got := exitCode(run()); got != 1 {
+			t.Errorf("Got: %d, Wanted: 1 for a run that timed out", got)
+		}
+	})
+}
+
+func TestRunWhenSullyGnomeIsDownExitStatus(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		writeTemplates(t, dir)
+		writeFile(t, filepath.Join(dir, "streamers.csv"), "bob,\n")
+		fakeSullyGnome(t, sullygnometest.Channel{Name: "bob", StreamLengths: []float32{0, 2}, Failure: sullygnometest.ServerError})
+
+		summaryPath := filepath.Join(dir, "summary.md")
+		t.Setenv("GITHUB_STEP_SUMMARY", summaryPath)
+
+		if got := exitCode(run()); got != exitTooManyFailures {
+			t.Errorf("Got: %d, Wanted: %d when every lookup fails", got, exitTooManyFailures)
+		}
+		if got := readFile(t, summaryPath); !strings.Contains(got, "Nothing was written") || !strings.Contains(got, "| bob |") {
+			t.Errorf("Got: %q, Wanted bob's failed lookup in the summary of the stopped run", got)
+		}
+	})
+}
+
+func TestConfigValidateCommand(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		writeTemplates(t, dir)
+		writeFile(t, filepath.Join(dir, "streamers.csv"), "name,youtube_url\n")
+
+		if err := command([]string{"config", "validate"}); err != nil {
+			t.Fatalf("Got: %v, Wanted the default config to be valid", err)
+		}
+
+		writeFile(t, filepath.Join(dir, "lists.json"), `{"window_days": 31, "providers": ["twitchtracker"]}`)
+		err := command([]string{"config", "validate", "lists.json"})
+		if err == nil || !strings.Contains(err.Error(), "window_days") || !strings.Contains(err.Error(), "twitchtracker") {
+			t.Errorf("Got: %v, Wanted window_days and provider errors", err)
+		}
+
+		if err := command([]string{"config", "check"}); err == nil {
+			t.Errorf("unknown commands should fail")
+		}
+	})
+}
+
+// fakeSullyGnome serves channels to the runs in t, which look the streamers up instead of reusing the last run.
+func fakeSullyGnome(t *testing.T, channels ...sullygnometest.Channel) {
+	t.Helper()
+
+	server := sullygnometest.NewServer(channels...)
+	t.Cleanup(server.Close)
+	t.Setenv("SECINFO_TEST", "")
+	t.Setenv("SECINFO_SULLYGNOME_URL", server.URL)
+}
+
 func withTempDir(t *testing.T, fn func(dir string)) {
 	t.Helper()
 
@@ -83,8 +531,11 @@ func withTempDir(t *testing.T, fn func(dir string)) {
 	if err := os.Chdir(dir); err != nil {
 		t.Fatalf("chdir failed: %v", err)
 	}
+	args := os.Args
+	os.Args = []string{"secinfo"}
 	t.Cleanup(func() {
 		_ = os.Chdir(cwd)
+		os.Args = args
 	})
 
 	fn(dir)
@@ -99,6 +550,7 @@ func writeTemplates(t *testing.T, dir string) {
 	}
 	writeFile(t, filepath.Join(templatesDir, "index.tmpl.md"), indexTemplate)
 	writeFile(t, filepath.Join(templatesDir, "inactive.tmpl.md"), inactiveTemplate)
+	writeFile(t, filepath.Join(templatesDir, "links.tmpl"), linksTemplate)
 }
 
 func writeFile(t *testing.T, path, content string) {
@@ -126,11 +578,22 @@ func writeJSON(t *testing.T, path string, value any) {
 	if err != nil {
 		t.Fatalf("json marshal failed: %v", err)
 	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
 	if err := os.WriteFile(path, data, 0644); err != nil {
 		t.Fatalf("write json failed: %v", err)
 	}
 }
 
+func readJSON(t *testing.T, path string, value any) {
+	t.Helper()
+
+	if err := json.Unmarshal([]byte(readFile(t, path)), value); err != nil {
+		t.Fatalf("json unmarshal of %s failed: %v", path, err)
+	}
+}
+
 func assertOrder(t *testing.T, content string, items []string) {
 	t.Helper()
 
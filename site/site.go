@@ -0,0 +1,131 @@
+/* Package site renders the streamer lists as a static HTML site with embedded templates and assets. */
+package site
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/infosecstreams/secinfo/history"
+	"github.com/infosecstreams/secinfo/output"
+	"github.com/infosecstreams/secinfo/render"
+	"github.com/infosecstreams/secinfo/streamers"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+//go:embed static
+var staticFS embed.FS
+
+// Options describe the list the site is for.
+type Options struct {
+	Title      string // The list's title, heading every page, e.g. InfoSec Streams
+	WindowDays int    // Days of activity the hours cover, streamers.WindowDays if zero
+	Sort       string // The name of the order the index lists streamers in, "hours" if empty
+}
+
+// page is the data every HTML template is executed with.
+type page struct {
+	Title    string          // The page title
+	Site     Options         // The list the site is for
+	Root     string          // Relative path back to the site root, e.g. "../"
+	Rows     []render.Row    // Streamers listed on the page
+	Streamer *render.Row     // The streamer a profile page is about
+	Profile  *render.Profile // The history of the streamer a profile page is about
+	Rank     int             // The streamer's position on the active list, 0 if inactive
+	List     render.Page     // The list the page was built from
+	Nav      []navLink       // Links to the other list pages
+}
+
+type navLink struct {
+	Title string
+	Href  string
+}
+
+var nav = []navLink{{"Active", "index.html"}, {"Inactive", "inactive.html"}}
+
+// Slug returns the file name, without extension, of a streamer's profile page.
+func Slug(name string) string {
+	return streamers.Slug(name)
+}
+
+// Render queues the whole site under dir into b: index.html, inactive.html, one page per streamer in
+// streamers/, and the static assets. Nothing is queued if any page fails to render.
+// The pages list streamers in the order given, ranks are by hours whatever that order is.
+// The profile pages show each streamer's history from h, and every page is headed with opts.Title.
+func Render(b *output.Batch, dir string, active, inactive render.Page, h history.History, opts Options) error {
+	var files output.Batch
+	if opts.WindowDays == 0 {
+		opts.WindowDays = streamers.WindowDays
+	}
+	if opts.Sort == "" {
+		opts.Sort = "hours"
+	}
+
+	if err := renderPage(&files, path.Join(dir, "index.html"), "index.html", opts, page{Title: opts.Title, Rows: active.Streamers, List: active}); err != nil {
+		return err
+	}
+	if err := renderPage(&files, path.Join(dir, "inactive.html"), "inactive.html", opts, page{Title: "Inactive " + opts.Title, Rows: inactive.Streamers, List: inactive}); err != nil {
+		return err
+	}
+	byHours := slices.Clone(active.Streamers)
+	slices.SortStableFunc(byHours, func(a, b render.Row) int { return streamers.ByHours(a.Streamer, b.Streamer) })
+	for i := range byHours {
+		row := byHours[i]
+		profile := render.NewProfile(row, h, active.GeneratedAt)
+		p := page{Title: row.Name, Root: "../", Streamer: &row, Profile: &profile, Rank: i + 1, List: active}
+		if err := renderPage(&files, path.Join(dir, "streamers", Slug(row.Name)+".html"), "streamer.html", opts, p); err != nil {
+			return err
+		}
+	}
+	for i := range inactive.Streamers {
+		row := inactive.Streamers[i]
+		profile := render.NewProfile(row, h, inactive.GeneratedAt)
+		p := page{Title: row.Name, Root: "../", Streamer: &row, Profile: &profile, List: inactive}
+		if err := renderPage(&files, path.Join(dir, "streamers", Slug(row.Name)+".html"), "streamer.html", opts, p); err != nil {
+			return err
+		}
+	}
+
+	err := fs.WalkDir(staticFS, "static", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := staticFS.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files.Add(path.Join(dir, strings.TrimPrefix(p, "static/")), data)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	files.Each(b.Add)
+	return nil
+}
+
+func renderPage(b *output.Batch, dest, name string, opts Options, p page) error {
+	p.Site, p.Nav = opts, nav
+	tmpl, err := template.New(name).Funcs(funcs).ParseFS(templateFS, "templates/layout.html", "templates/"+name)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "layout", p); err != nil {
+		return err
+	}
+	b.Add(dest, buf.Bytes())
+	return nil
+}
+
+var funcs = template.FuncMap{
+	"slug":     Slug,
+	"langBase": streamers.LangBase,
+}
@@ -0,0 +1,98 @@
+package site_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/infosecstreams/secinfo/changes"
+	"github.com/infosecstreams/secinfo/history"
+	"github.com/infosecstreams/secinfo/output"
+	"github.com/infosecstreams/secinfo/render"
+	"github.com/infosecstreams/secinfo/site"
+	"github.com/infosecstreams/secinfo/streamers"
+)
+
+func TestSlug(t *testing.T) {
+	for name, want := range map[string]string{
+		"Security_Live": "security_live",
+		"0xRy4nG":       "0xry4ng",
+		"../etc/passwd": "etcpasswd",
+		"":              "_",
+	} {
+		if got := site.Slug(name); got != want {
+			t.Errorf("Slug(%q) Got: %q, Wanted: %q", name, got, want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	alice := streamers.Streamer{Name: "Alice", ThirtyDayStats: 12, Lang: "de-AT", Online: true, Tags: []streamers.Tag{streamers.Malware}, DerivedTags: []streamers.Tag{streamers.Dev}}
+	alice.SetAccount(streamers.Account{Platform: streamers.Twitch, Handle: "Alice"})
+	alice.SetAccount(streamers.Account{Platform: streamers.YouTube, URL: "https://www.youtube.com/channel/UC123"})
+	bob := streamers.Streamer{Name: "<bob>"}
+	bob.SetAccount(streamers.Account{Platform: streamers.Twitch, Handle: "bob"})
+
+	active := render.NewPage(streamers.StreamerList{Streamers: []streamers.Streamer{alice}})
+	inactive := render.NewPage(streamers.StreamerList{Streamers: []streamers.Streamer{bob}})
+
+	var h history.History
+	h.Record(changes.Run{Active: []streamers.Streamer{alice}, Inactive: []streamers.Streamer{bob}}, time.Date(2024, 5, 10, 18, 0, 0, 0, time.UTC))
+	active.SetHistory(h)
+
+	var b output.Batch
+	if err := site.Render(&b, "public", active, inactive, h, site.Options{Title: "CTF Streams", WindowDays: 7, Sort: "name"}); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	files := map[string]string{}
+	b.Each(func(path string, data []byte) { files[path] = string(data) })
+
+	for _, path := range []string{"public/index.html", "public/inactive.html", "public/streamers/alice.html", "public/streamers/bob.html", "public/style.css", "public/sort.js"} {
+		if _, ok := files[path]; !ok {
+			t.Errorf("missing %s, got: %v", path, b.Paths())
+		}
+	}
+
+	index := files["public/index.html"]
+	for _, want := range []string{
+		`<a href="streamers/alice.html">Alice</a>`,
+		`<a class="platform youtube" href="https://www.youtube.com/channel/UC123" title="youtube">youtube</a>`,
+		`<tr class="online" data-tags="malware dev" data-lang="de">`,
+		`<button type="button" class="chip" data-filter="tags" data-value="malware" aria-pressed="false">Malware <span class="count">1</span></button>`,
+		`<button type="button" class="chip" data-filter="lang" data-value="de" lang="de" aria-pressed="false">Deutsch <span class="count">1</span></button>`,
+		`<td lang="de-AT">Österreichisches Deutsch</td>`,
+		`<td>Malware, Dev</td>`,
+		`<script src="sort.js" defer></script>`,
+		`<title>CTF Streams</title>`,
+		`<h1><a href="index.html">CTF Streams</a></h1>`,
+		`Information Security-related streams, sorted by name.`,
+		`<th data-sort="number">Hours (7d)</th>`,
+		`<td data-value="1">1</td>`,
+	} {
+		if !strings.Contains(index, want) {
+			t.Errorf("index.html is missing %q", want)
+		}
+	}
+	if strings.Contains(index, "cdnjs") || strings.Contains(index, "js/sort.js") {
+		t.Errorf("index.html should not depend on external scripts")
+	}
+
+	if !strings.Contains(files["public/inactive.html"], "in the last 7 days") {
+		t.Errorf("inactive.html is missing the window:\n%s", files["public/inactive.html"])
+	}
+	if !strings.Contains(files["public/inactive.html"], "&lt;bob&gt;") {
+		t.Errorf("inactive.html should escape names:\n%s", files["public/inactive.html"])
+	}
+	if !strings.Contains(files["public/streamers/alice.html"], "Active, #1 of 1") {
+		t.Errorf("alice.html is missing rank:\n%s", files["public/streamers/alice.html"])
+	}
+	for _, want := range []string{"<dt>Hours streamed (7 days)</dt>", "<dd>Friday</dd>", "<dd>18:00 UTC</dd>", "<dd>In 1 update, the only", "<td>12.0</td><td>#1</td>", "Before additions were recorded"} {
+		if !strings.Contains(files["public/streamers/alice.html"], want) {
+			t.Errorf("alice.html is missing %q:\n%s", want, files["public/streamers/alice.html"])
+		}
+	}
+	if !strings.Contains(files["public/streamers/alice.html"], `<link rel="stylesheet" href="../style.css">`) {
+		t.Errorf("alice.html should link the stylesheet relative to the root")
+	}
+}
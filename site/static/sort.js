@@ -0,0 +1,89 @@
+// Client-side sorting and filtering for tables with class "sortable".
+// Headers with data-sort="number" or data-sort="text" sort their column on click,
+// cells may carry a data-value to sort by instead of their text.
+// A pressed chip with data-filter and data-value only shows the rows whose attribute
+// "data-" + data-filter has that value, e.g. data-filter="tags" data-value="ctf".
+(function () {
+  "use strict";
+
+  function cellValue(row, index, type) {
+    var cell = row.cells[index];
+    var value = cell.hasAttribute("data-value") ? cell.getAttribute("data-value") : cell.textContent.trim();
+    return type === "number" ? parseFloat(value) || 0 : value.toLowerCase();
+  }
+
+  function sortBy(table, th) {
+    var index = Array.prototype.indexOf.call(th.parentNode.children, th);
+    var type = th.getAttribute("data-sort");
+    var ascending = th.getAttribute("aria-sort") !== "ascending";
+    var body = table.tBodies[0];
+    var rows = Array.prototype.slice.call(body.rows);
+
+    rows.sort(function (a, b) {
+      var x = cellValue(a, index, type);
+      var y = cellValue(b, index, type);
+      var order = type === "number" ? x - y : x.localeCompare(y);
+      return ascending ? order : -order;
+    });
+    rows.forEach(function (row) {
+      body.appendChild(row);
+    });
+
+    Array.prototype.forEach.call(th.parentNode.children, function (other) {
+      other.removeAttribute("aria-sort");
+    });
+    th.setAttribute("aria-sort", ascending ? "ascending" : "descending");
+  }
+
+  var query = "";
+  var chosen = {};
+
+  function matches(row) {
+    return Object.keys(chosen).every(function (key) {
+      var values = (row.getAttribute("data-" + key) || "").split(" ");
+      return chosen[key] === "" || values.indexOf(chosen[key]) !== -1;
+    });
+  }
+
+  function filter(table) {
+    var q = query.trim().toLowerCase();
+    Array.prototype.forEach.call(table.tBodies[0].rows, function (row) {
+      row.hidden = (q !== "" && row.textContent.toLowerCase().indexOf(q) === -1) || !matches(row);
+    });
+  }
+
+  function filterAll() {
+    document.querySelectorAll("table.sortable").forEach(filter);
+  }
+
+  document.querySelectorAll("table.sortable").forEach(function (table) {
+    table.querySelectorAll("th[data-sort]").forEach(function (th) {
+      th.addEventListener("click", function () {
+        sortBy(table, th);
+      });
+    });
+  });
+
+  document.querySelectorAll("input.filter").forEach(function (input) {
+    input.addEventListener("input", function () {
+      query = input.value;
+      filterAll();
+    });
+  });
+
+  var chips = document.querySelectorAll("button.chip[data-filter]");
+  chips.forEach(function (chip) {
+    chip.addEventListener("click", function () {
+      var key = chip.getAttribute("data-filter");
+      var pressed = chip.getAttribute("aria-pressed") !== "true";
+      chips.forEach(function (other) {
+        if (other.getAttribute("data-filter") === key) {
+          other.setAttribute("aria-pressed", "false");
+        }
+      });
+      chip.setAttribute("aria-pressed", pressed ? "true" : "false");
+      chosen[key] = pressed ? chip.getAttribute("data-value") : "";
+      filterAll();
+    });
+  });
+})();
@@ -0,0 +1,61 @@
+package streamers
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// ParseLang returns the canonical BCP 47 form of a language tag, e.g. "EN" is "en" and "pt_br" is "pt-BR".
+func ParseLang(s string) (string, error) {
+	tag, err := language.Parse(strings.ReplaceAll(strings.TrimSpace(s), "_", "-"))
+	if err != nil {
+		return "", fmt.Errorf("language %q: %w", s, err)
+	}
+	return tag.String(), nil
+}
+
+// LangBase returns the language of a tag without its region or script, e.g. "pt" for "pt-BR".
+// Streamers are grouped by it, so viewers of every variant of a language find each other.
+func LangBase(lang string) string {
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return ""
+	}
+	base, _ := tag.Base()
+	return base.String()
+}
+
+// LangName returns the name of a language in that language, e.g. "Deutsch" for "de", or the tag if it has none.
+func LangName(lang string) string {
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return lang
+	}
+	if name := display.Self.Name(tag); name != "" {
+		return name
+	}
+	return lang
+}
+
+// LangName returns the name of the streamer's language in that language, empty if it isn't known.
+func (s Streamer) LangName() string {
+	if s.Lang == "" {
+		return ""
+	}
+	return LangName(s.Lang)
+}
+
+// InLanguage returns the streamers whose language has the base language of lang, in order.
+func (sl StreamerList) InLanguage(lang string) StreamerList {
+	base := LangBase(lang)
+	filtered := StreamerList{}
+	for _, s := range sl.Streamers {
+		if s.Lang != "" && LangBase(s.Lang) == base {
+			filtered.Streamers = append(filtered.Streamers, s)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,87 @@
+package streamers
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// Order is a sort strategy for streamers. It returns a negative number when a sorts before b,
+// a positive number when a sorts after b, and zero only for streamers with the same name.
+type Order func(a, b Streamer) int
+
+// Orders are the sort strategies by name.
+var Orders = map[string]Order{
+	"hours":  ByHours,
+	"name":   ByName,
+	"live":   ByLastLive,
+	"newest": ByNewest,
+}
+
+// OrderNames lists the names of Orders.
+var OrderNames = []string{"hours", "name", "live", "newest"}
+
+var names = struct {
+	sync.Mutex
+	*collate.Collator
+}{Collator: collate.New(language.Und, collate.IgnoreCase)}
+
+// CompareNames compares names the way people expect a list to be sorted: ignoring case, with accented letters
+// next to their base letter, e.g. "Émile" between "Eli" and "Fred". Names that only differ in case compare bytewise.
+func CompareNames(a, b string) int {
+	names.Lock()
+	c := names.CompareString(a, b)
+	names.Unlock()
+	if c == 0 {
+		return strings.Compare(a, b)
+	}
+	return c
+}
+
+// ByName sorts streamers by name, see CompareNames.
+func ByName(a, b Streamer) int {
+	return CompareNames(a.Name, b.Name)
+}
+
+// ByHours sorts streamers by ThirtyDayStats, most first, then by name.
+func ByHours(a, b Streamer) int {
+	switch {
+	case a.ThirtyDayStats > b.ThirtyDayStats:
+		return -1
+	case a.ThirtyDayStats < b.ThirtyDayStats:
+		return 1
+	}
+	return ByName(a, b)
+}
+
+// ByLastLive sorts the streamers that were live most recently first, then by hours. Never seen live sorts last.
+func ByLastLive(a, b Streamer) int {
+	if c := b.LastLive.Compare(a.LastLive); c != 0 {
+		return c
+	}
+	return ByHours(a, b)
+}
+
+// ByNewest sorts the streamers that were added to the list most recently first, then by name.
+// Streamers added before additions were recorded sort last.
+func ByNewest(a, b Streamer) int {
+	if c := b.Added.Compare(a.Added); c != 0 {
+		return c
+	}
+	return ByName(a, b)
+}
+
+// SortBy sorts the list with order.
+func (sl *StreamerList) SortBy(order Order) {
+	slices.SortStableFunc(sl.Streamers, order)
+}
+
+// Sorted returns a sorted copy of the list, leaving the list as it was.
+func (sl StreamerList) Sorted(order Order) StreamerList {
+	sorted := StreamerList{Streamers: slices.Clone(sl.Streamers)}
+	sorted.SortBy(order)
+	return sorted
+}
@@ -1,31 +1,189 @@
-/* Package streamers extracts 30-day streaming statistics and generates sorted markdown. */
+/* Package streamers extracts 30-day streaming statistics and keeps the sorted streamer lists. */
 // BUG(🐛): there are bugs in here.
 package streamers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"io/ioutil"
-	"log"
 	"net/http"
+	"net/url"
+	"path"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/spf13/afero"
 )
 
-// Streamer is a struct that contains the name of a streamer and the YouTube channel url.
+// Platform identifies a streaming service a streamer broadcasts on.
+type Platform string
+
+// Supported platforms, in the order their links are rendered.
+const (
+	Twitch   Platform = "twitch"
+	YouTube  Platform = "youtube"
+	Kick     Platform = "kick"
+	Owncast  Platform = "owncast"
+	PeerTube Platform = "peertube"
+)
+
+// Platforms lists every supported Platform in rendering order.
+var Platforms = []Platform{Twitch, YouTube, Kick, Owncast, PeerTube}
+
+// Account is a streamer's presence on a single platform.
+type Account struct {
+	Platform Platform // The platform the account lives on
+	Handle   string   // The account's handle on the platform
+	URL      string   // The public url of the account
+}
+
+// Streamer is a struct that contains the name of a streamer and their platform accounts.
+// In the legacy 'name,youtube_url' csv format the name doubles as the streamer's Twitch handle.
 // SullyGnomeID and ThirtyDayStats are fetched from SullyGnome.com.
 // (sorry for lightly gathering a small amount of info every 24 hours).
 type Streamer struct {
-	Name           string  // The name of the streamer
-	YTURL          string  // The url of the streamer's YouTube channel
-	SullyGnomeID   string  // The SullyGnome ID of the streamer
-	ThirtyDayStats float32 // Hours streamed in the last 30 days
-	Lang           string  // The streamer's language. If they are online this is used in the generated markdown.
-	WasInactive    bool    `json:"-"` // Whether the streamer came from inactive_streamers.csv
+	Name           string    // The name of the streamer
+	Accounts       []Account // The streamer's accounts, one per platform
+	SullyGnomeID   string    // The SullyGnome ID of the streamer
+	ThirtyDayStats float32   // Hours streamed in the last 30 days, summed across platforms
+	Lang           string    // The streamer's language as a BCP 47 tag, e.g. "en" or "pt-BR", from the csv or while they're live
+	Tags           []Tag     `json:",omitempty"` // Topics the streamer covers, from the csv
+	Category       string    `json:",omitempty"` // The category the streamer streams in most, if tags are derived
+	StreamLengths  []float32 `json:",omitempty"` // How many streams lasted 1, 2, 3... hours during the stats window, from SullyGnome
+	DerivedTags    []Tag     `json:",omitempty"` // Topics derived from Category
+	Added          time.Time `json:",omitzero"`  // When the streamer was first seen on the list, zero if before this was recorded
+	LastLive       time.Time `json:",omitzero"`  // When the streamer was last seen live
+	Online         bool      `json:",omitempty"` // Whether the streamer was live during the last run
+	WasInactive    bool      `json:"-"`          // Whether the streamer came from inactive_streamers.csv
+	LangFromCSV    bool      `json:"-"`          // Whether Lang came from the csv, only then is it written back to it
+}
+
+// UnmarshalJSON decodes a Streamer, upgrading the legacy fields into Accounts: YTURL into a YouTube account and,
+// for json from before accounts were kept, the name into a Twitch one.
+func (s *Streamer) UnmarshalJSON(data []byte) error {
+	type plain Streamer
+	var legacy struct {
+		plain
+		YTURL string
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+	*s = Streamer(legacy.plain)
+	if len(s.Accounts) > 0 {
+		return nil
+	}
+	s.SetAccount(Account{Platform: Twitch, Handle: s.Name})
+	if legacy.YTURL != "" {
+		s.SetAccount(Account{Platform: YouTube, URL: legacy.YTURL})
+	}
+	return nil
+}
+
+// Account returns the streamer's account on platform p, if they have one.
+func (s Streamer) Account(p Platform) (Account, bool) {
+	for _, a := range s.Accounts {
+		if a.Platform == p {
+			return a, true
+		}
+	}
+	return Account{}, false
+}
+
+// SetAccount adds or replaces the streamer's account on a.Platform, filling in a missing URL or handle.
+// Accounts are kept in the order of Platforms.
+func (s *Streamer) SetAccount(a Account) {
+	if a.URL == "" {
+		a.URL = profileURL(a.Platform, a.Handle)
+	}
+	if a.Handle == "" {
+		a.Handle = handleFromURL(a.URL)
+	}
+	if a.URL == "" {
+		return
+	}
+	accounts := make([]Account, 0, len(s.Accounts)+1)
+	for _, existing := range s.Accounts {
+		if existing.Platform != a.Platform {
+			accounts = append(accounts, existing)
+		}
+	}
+	accounts = append(accounts, a)
+	sort.SliceStable(accounts, func(i, j int) bool {
+		return platformRank(accounts[i].Platform) < platformRank(accounts[j].Platform)
+	})
+	s.Accounts = accounts
+}
+
+// Slug returns a lower-case, file name safe form of a streamer's name, for per-streamer output files.
+func Slug(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+// YouTubeURL returns the url of the streamer's YouTube channel, or an empty string.
+func (s Streamer) YouTubeURL() string {
+	a, _ := s.Account(YouTube)
+	return a.URL
+}
+
+// profileURL builds the canonical url for a handle on platforms that have one.
+// Self-hosted platforms (Owncast, PeerTube) have no canonical url and must be given one.
+func profileURL(p Platform, handle string) string {
+	if handle == "" {
+		return ""
+	}
+	switch p {
+	case Twitch:
+		return "https://www.twitch.tv/" + handle
+	case YouTube:
+		return "https://www.youtube.com/@" + strings.TrimPrefix(handle, "@")
+	case Kick:
+		return "https://kick.com/" + handle
+	}
+	return ""
+}
+
+// handleFromURL uses the last path element of a url as the account handle, or the host if there is no path.
+func handleFromURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	if p := strings.Trim(u.Path, "/"); p != "" {
+		return path.Base(p)
+	}
+	return u.Host
+}
+
+func platformRank(p Platform) int {
+	for i, known := range Platforms {
+		if known == p {
+			return i
+		}
+	}
+	return len(Platforms)
+}
+
+// parsePlatform returns the Platform named by s, if it's supported.
+func parsePlatform(s string) (Platform, bool) {
+	p := Platform(strings.ToLower(strings.TrimSpace(s)))
+	return p, platformRank(p) < len(Platforms)
 }
 
 // StreamList is uhh... a list of Streamers.
@@ -38,9 +196,9 @@ func (sl StreamerList) Len() int {
 	return len(sl.Streamers)
 }
 
-// Less returns a bool for i > j, used to implment sort.Interface.
+// Less returns a bool for i > j, used to implment sort.Interface. Ties are broken by name, see ByHours.
 func (sl StreamerList) Less(i, j int) bool {
-	return sl.Streamers[i].ThirtyDayStats > sl.Streamers[j].ThirtyDayStats
+	return ByHours(sl.Streamers[i], sl.Streamers[j]) < 0
 }
 
 // Swap swaps the elements at i and j in the StreamerList, used to implement sort.Interface.
@@ -53,11 +211,9 @@ func (sl *StreamerList) Sort() {
 	sort.Sort(sl)
 }
 
-// SortByName sorts streamers by name case-insensitively for human-readable CSV output.
+// SortByName sorts streamers by name for human-readable CSV output, see ByName.
 func (sl *StreamerList) SortByName() {
-	sort.SliceStable(sl.Streamers, func(i, j int) bool {
-		return strings.ToLower(sl.Streamers[i].Name) < strings.ToLower(sl.Streamers[j].Name)
-	})
+	sl.SortBy(ByName)
 }
 
 // ContainsStreamer returns true if a streamer with the same name exists in the list.
@@ -81,6 +237,9 @@ func (sl StreamerList) RemoveStreamer(streamer Streamer) StreamerList {
 	return filtered
 }
 
+// WindowDays is the number of days of activity ThirtyDayStats covers.
+const WindowDays = 30
+
 // SullyGnomeStats is a struct to deserialize the 30-day streaming statistics json response.
 type SullyGnomeStats struct {
 	Data struct {
@@ -90,121 +249,355 @@ type SullyGnomeStats struct {
 	} `json:"data"`
 }
 
+// StatsProvider reports 30-day streaming hours for accounts on a single platform.
+type StatsProvider interface {
+	Platform() Platform                                                 // The platform the provider has stats for
+	Hours(ctx context.Context, s *Streamer, a Account) (float32, error) // Hours streamed by a in the last 30 days
+}
+
+// CollectStats sets ThirtyDayStats to the hours summed across every account that has a provider.
+// Accounts on platforms without a provider are skipped, so a streamer with none counts no hours. Failed lookups are joined into the returned
+// error, and the hours from accounts that did succeed are still counted.
+func (s *Streamer) CollectStats(ctx context.Context, providers ...StatsProvider) error {
+	var total float32
+	var errs []error
+	for _, a := range s.Accounts {
+		for _, p := range providers {
+			if p.Platform() != a.Platform {
+				continue
+			}
+			hours, err := p.Hours(ctx, s, a)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", a.Platform, err))
+				continue
+			}
+			total += hours
+		}
+	}
+	s.ThirtyDayStats = total
+	return errors.Join(errs...)
+}
+
+// StatsCache remembers what providers returned for each account, so a streamer on several lists is only looked up once.
+// It's safe for concurrent use.
+type StatsCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedStats
+}
+
+type cachedStats struct {
+	hours    float32
+	err      error
+	name     string    // The streamer's name after the lookup, providers may fix its case
+	id       string    // The streamer's SullyGnomeID after the lookup
+	account  Account   // The account after the lookup
+	category string    // The account's top category, for category lookups
+	lengths  []float32 // The streamer's StreamLengths after the lookup
+}
+
+// Provider returns a StatsProvider that answers from the cache, and asks p on a miss.
+func (c *StatsCache) Provider(p StatsProvider) StatsProvider {
+	return cachedProvider{cache: c, provider: p}
+}
+
+type cachedProvider struct {
+	cache    *StatsCache
+	provider StatsProvider
+}
+
+func (cp cachedProvider) Platform() Platform {
+	return cp.provider.Platform()
+}
+
+// Hours returns the cached hours of the account, and applies what the first lookup changed about the streamer.
+// Lookups cut short by ctx aren't cached.
+func (cp cachedProvider) Hours(ctx context.Context, s *Streamer, a Account) (float32, error) {
+	key := string(a.Platform) + "/" + strings.ToLower(a.Handle+a.URL)
+	cp.cache.mu.Lock()
+	defer cp.cache.mu.Unlock()
+
+	if e, ok := cp.cache.entries[key]; ok {
+		s.Name, s.SullyGnomeID = e.name, e.id
+		s.SetAccount(e.account)
+		if e.lengths != nil {
+			s.StreamLengths = e.lengths
+		}
+		return e.hours, e.err
+	}
+
+	hours, err := cp.provider.Hours(ctx, s, a)
+	if ctx.Err() != nil {
+		return hours, err
+	}
+	account, _ := s.Account(a.Platform)
+	if cp.cache.entries == nil {
+		cp.cache.entries = map[string]cachedStats{}
+	}
+	cp.cache.entries[key] = cachedStats{hours: hours, err: err, name: s.Name, id: s.SullyGnomeID, account: account, lengths: s.StreamLengths}
+	return hours, err
+}
+
+// Categories returns a CategoryProvider that answers from the cache, and asks p on a miss.
+func (c *StatsCache) Categories(p CategoryProvider) CategoryProvider {
+	return cachedCategories{cache: c, provider: p}
+}
+
+type cachedCategories struct {
+	cache    *StatsCache
+	provider CategoryProvider
+}
+
+func (cc cachedCategories) Platform() Platform {
+	return cc.provider.Platform()
+}
+
+// TopCategory returns the cached category of the account.
+func (cc cachedCategories) TopCategory(ctx context.Context, s *Streamer, a Account) (string, error) {
+	key := "category/" + string(a.Platform) + "/" + strings.ToLower(a.Handle+a.URL)
+	cc.cache.mu.Lock()
+	defer cc.cache.mu.Unlock()
+
+	if e, ok := cc.cache.entries[key]; ok {
+		return e.category, e.err
+	}
+	category, err := cc.provider.TopCategory(ctx, s, a)
+	if ctx.Err() != nil {
+		return category, err
+	}
+	if cc.cache.entries == nil {
+		cc.cache.entries = map[string]cachedStats{}
+	}
+	cc.cache.entries[key] = cachedStats{category: category, err: err}
+	return category, err
+}
+
+// SullyGnomeURL is the default base url of SullyGnome.
+const SullyGnomeURL = "https://sullygnome.com"
+
+// DefaultUserAgent is the User-Agent sent to SullyGnome when none is configured.
+const DefaultUserAgent = "Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:99.0) Gecko/20100101 Firefox/99.0"
+
+// SullyGnomeWindows are the numbers of days SullyGnome has statistics for.
+var SullyGnomeWindows = []int{3, 7, 14, 30, 90, 180, 365}
+
+// SullyGnome is a StatsProvider for Twitch accounts backed by SullyGnome.com.
+// The zero value looks up the last WindowDays of stats on SullyGnomeURL.
+type SullyGnome struct {
+	BaseURL    string       // SullyGnome's url, SullyGnomeURL if empty
+	UserAgent  string       // The User-Agent to send, DefaultUserAgent if empty
+	WindowDays int          // Days of activity to count, WindowDays if zero
+	Client     *http.Client // The client to send requests with, http.DefaultClient if nil
+}
+
+// Platform returns Twitch.
+func (SullyGnome) Platform() Platform {
+	return Twitch
+}
+
+// Hours looks up the streamer's SullyGnomeID if it's missing, then fetches their 30-day hours.
+// The histogram the hours are summed from is kept in StreamLengths.
+func (sg SullyGnome) Hours(ctx context.Context, s *Streamer, a Account) (float32, error) {
+	if s.SullyGnomeID == "" {
+		if err := sg.lookupUID(ctx, s); err != nil {
+			return 0, err
+		}
+	}
+	lengths, err := sg.fetchStreamLengths(ctx, s.SullyGnomeID, s.Name)
+	if err != nil {
+		return 0, err
+	}
+	s.StreamLengths = lengths
+	return sumLengths(lengths), nil
+}
+
 // GetUID populates the Streamer struct's SullyGnomeID field.
-func (s *Streamer) GetUID() {
+func (s *Streamer) GetUID() error {
+	return SullyGnome{}.lookupUID(context.Background(), s)
+}
+
+func (sg SullyGnome) lookupUID(ctx context.Context, s *Streamer) error {
+	// Look up the Twitch account, the name is the handle for streamers from before accounts were declared
+	handle := s.Name
+	if a, ok := s.Account(Twitch); ok {
+		handle = a.Handle
+	}
+
 	// Make a net/http get request to get the UID
 	// The URL is f'https://sullygnome.com/channel/%s/30/activitystats'
-	url := "https://sullygnome.com/channel/" + s.Name + "/30/activitystats"
+	url := sg.baseURL() + "/channel/" + handle + "/" + strconv.Itoa(sg.windowDays()) + "/activitystats"
 
 	// Create a new GET request
-	request, err := http.NewRequest("GET", url, nil)
+	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		fmt.Println(err)
+		return err
 	}
 
-	// Set a User-Agent header
-	request.Header.Set("user-agent", "Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:99.0) Gecko/20100101 Firefox/99.0")
-
-	// Create a new http client
-	client := &http.Client{}
-
 	// Send the request
-	r, err := client.Do(request)
+	r, err := sg.do(request)
 	if err != nil {
-		log.Printf("Error fetching UID for %s: %s\n", s.Name, err)
-		return
+		return fmt.Errorf("error fetching UID for %s: %w", handle, err)
 	}
 	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK && r.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("error fetching UID for %s: %s", handle, r.Status)
+	}
 
 	// Read the response
-	b, err := ioutil.ReadAll(r.Body)
+	b, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error reading UID response for %s: %s\n", s.Name, err)
-		return
+		return fmt.Errorf("error reading UID response for %s: %w", s.Name, err)
 	}
 
 	// Convert the response to a string
 	str_response := string(b)
 
 	// Check if response contains username
-	if !strings.Contains(str_response, s.Name) {
-		log.Printf("streamer hasn't streamed in a while! username not found, check spelling: %s, check twitch: https://www.twitch.tv/%s/schedule, stats: %s\n", s.Name, s.Name, url)
-		return
+	if r.StatusCode == http.StatusNotFound || !strings.Contains(str_response, handle) {
+		return fmt.Errorf("streamer hasn't streamed in a while! username not found, check spelling: %s, check twitch: https://www.twitch.tv/%s/schedule, stats: %s", handle, handle, url)
 	}
 
 	// Parse the body for '<span class="PageHeaderMiddleWithImageHeaderP1">'
-	user_response := strings.Split(str_response, "<span class=\"PageHeaderMiddleWithImageHeaderP1\">")[1]
+	_, user_response, found := strings.Cut(str_response, "<span class=\"PageHeaderMiddleWithImageHeaderP1\">")
+	if !found {
+		return fmt.Errorf("no page header in UID response for %s", s.Name)
+	}
 	// Remove everything after '</span>'
-	user_response = strings.Split(user_response, "</span>")[0]
+	user_response, _, _ = strings.Cut(user_response, "</span>")
 	// Parse the body for 'var PageInfo = '
-	str_response = strings.Split(str_response, "var PageInfo = ")[1]
+	_, str_response, found = strings.Cut(str_response, "var PageInfo = ")
+	if !found {
+		return fmt.Errorf("no PageInfo in UID response for %s", s.Name)
+	}
 	// Split on ;
-	str_response = strings.Split(str_response, ";")[0]
+	str_response, _, _ = strings.Cut(str_response, ";")
 	// Read the resulting string as json
 	var j map[string]interface{}
 	err = json.Unmarshal([]byte(str_response), &j)
 	if err != nil {
-		fmt.Println(err)
+		return fmt.Errorf("error decoding PageInfo for %s: %w", s.Name, err)
 	}
 
 	// Set the SullyGnomeID
 	id := fmt.Sprintf("%.0f", j["id"])
 	s.SullyGnomeID = id
-	s.Name = user_response
+	// SullyGnome has the handle's case right, fix the name too when it's the handle
+	if strings.EqualFold(s.Name, handle) {
+		s.Name = user_response
+	}
+	s.SetAccount(Account{Platform: Twitch, Handle: user_response})
+	return nil
+}
+
+// GetStats populates the Streamer struct's ThirtyDayStats field with 30-day Twitch streaming statistics.
+func (s *Streamer) GetStats() error {
+	lengths, err := SullyGnome{}.fetchStreamLengths(context.Background(), s.SullyGnomeID, s.Name)
+	if err != nil {
+		return err
+	}
+	s.ThirtyDayStats, s.StreamLengths = sumLengths(lengths), lengths
+	return nil
 }
 
-// GetStats populates the Streamer struct's ThirtyDayStats field with 30-day streaming statistics.
-func (s *Streamer) GetStats() {
+// fetchStreamLengths returns SullyGnome's histogram of a channel's streams by length, the number of 1, 2, 3... hour streams.
+func (sg SullyGnome) fetchStreamLengths(ctx context.Context, id, name string) ([]float32, error) {
 	// Check that the streamer has a SullyGnomeID and not an empty string
-	if s.SullyGnomeID == "" {
-		fmt.Printf("streamer has no SullyGnomeID: %s\n", s.Name)
-		return
+	if id == "" {
+		return nil, fmt.Errorf("streamer has no SullyGnomeID: %s", name)
 	}
 
 	// Make a new GET request to get the stats
 	// The URL is f'https://sullygnome.com/api/charts/barcharts/getconfig/channelhourstreams/30/{uid}/{username}/%20/%20/0/0/%20/0/0/'
-	request, err := http.NewRequest("GET", "https://sullygnome.com/api/charts/barcharts/getconfig/channelhourstreams/30/"+s.SullyGnomeID+"/"+s.Name+"/%20/%20/0/0/%20/0/0/", nil)
+	request, err := http.NewRequestWithContext(ctx, "GET", sg.baseURL()+"/api/charts/barcharts/getconfig/channelhourstreams/"+strconv.Itoa(sg.windowDays())+"/"+id+"/"+name+"/%20/%20/0/0/%20/0/0/", nil)
 	if err != nil {
-		fmt.Printf("Error creating request: %s\n", err)
+		return nil, fmt.Errorf("error creating request: %w", err)
 	}
 
-	// Set a User-Agent header
-	request.Header.Set("user-agent", "Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:99.0) Gecko/20100101 Firefox/99.0")
-
-	// Create a new http client
-	client := &http.Client{}
-
 	// Send the request
-	r, err := client.Do(request)
+	r, err := sg.do(request)
 	if err != nil {
-		log.Printf("Error sending stats request for %s: %s\n", s.Name, err)
-		return
+		return nil, fmt.Errorf("error sending stats request for %s: %w", name, err)
 	}
 	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching stats for %s: %s", name, r.Status)
+	}
 
 	// Parse the JSON response into SullyGnomeStats struct
-	var sg SullyGnomeStats
-	err = json.NewDecoder(r.Body).Decode(&sg)
+	var stats SullyGnomeStats
+	err = json.NewDecoder(r.Body).Decode(&stats)
 	if err != nil {
-		log.Printf("Error decoding stats response for %s: %s\n", s.Name, err)
-		return
+		return nil, fmt.Errorf("error decoding stats response for %s: %w", name, err)
+	}
+	if len(stats.Data.Datasets) == 0 {
+		return nil, fmt.Errorf("no datasets in stats response for %s", name)
+	}
+	return stats.Data.Datasets[0].Data, nil
+}
+
+// TypicalStream returns the stream length in hours the streamer streamed most often, 0 without StreamLengths.
+func (s Streamer) TypicalStream() int {
+	var typical int
+	var most float32
+	for i, n := range s.StreamLengths {
+		if n > most {
+			typical, most = i+1, n
+		}
 	}
+	return typical
+}
 
+// sumLengths returns the hours streamed in a histogram of streams by length.
+func sumLengths(lengths []float32) float32 {
 	// Sum up the 30 day stats by mutiplying each data by index+1.0
 	var sum float32
-	for i, data := range sg.Data.Datasets[0].Data {
+	for i, data := range lengths {
 		sum += data * float32(i+1)
 	}
-	s.ThirtyDayStats = sum
+	return sum
+}
+
+// do sends the request with the configured User-Agent and client.
+func (sg SullyGnome) do(request *http.Request) (*http.Response, error) {
+	userAgent := sg.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	request.Header.Set("user-agent", userAgent)
+
+	client := sg.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(request)
+}
+
+func (sg SullyGnome) baseURL() string {
+	if sg.BaseURL == "" {
+		return SullyGnomeURL
+	}
+	return strings.TrimSuffix(sg.BaseURL, "/")
+}
+
+func (sg SullyGnome) windowDays() int {
+	if sg.WindowDays == 0 {
+		return WindowDays
+	}
+	return sg.WindowDays
 }
 
 // OnlineNow returns a bool whether the streamer is online(🟢) or not in "index.md".
+// A streamer without a language takes the one in the last column of their line, if it's a valid tag.
 func (s *Streamer) OnlineNow(indexText string) bool {
 	// Read index.md and search for the streamer's name to see if the line contains "🟢"
 	for _, line := range strings.Split(indexText, "\n") {
 		if strings.Contains(line, s.Name) {
 			if strings.Contains(line, "🟢") {
-				s.Lang = line[len(line)-2:]
+				if s.Lang == "" {
+					cell := strings.TrimSpace(line[strings.LastIndex(line, "|")+1:])
+					if lang, err := ParseLang(cell); err == nil && cell != "" {
+						s.Lang = lang
+					}
+				}
 				return true
 			}
 		}
@@ -212,45 +605,23 @@ func (s *Streamer) OnlineNow(indexText string) bool {
 	return false
 }
 
-// ReturnMarkdownLine returns a GitHub markdown-flavored line for 'index.md' or 'inactive.md'.
-// If the stream has > 0 hours over 30 days, it will return a line that contains columns for the 🟢 and Twitch/YouTube links.
-// Otherwise if will return a line that just contains the streamer + links for inactive.md.
-func (s Streamer) ReturnMarkdownLine(online bool) (string, error) {
-	var line string
-	if s.ThirtyDayStats > 0 { // active streamer
-		if online { // online
-			if s.YTURL != "" {
-				line = fmt.Sprintf("🟢 | `%s` | [<i class=\"fab fa-twitch\" style=\"color:#9146FF\"></i>](https://www.twitch.tv/%s) &nbsp; [<i class=\"fab fa-youtube\" style=\"color:#C00\"></i>](%s) | %s\n", s.Name, s.Name, s.YTURL, s.Lang)
-			} else {
-				line = fmt.Sprintf("🟢 | `%s` | [<i class=\"fab fa-twitch\" style=\"color:#9146FF\"></i>](https://www.twitch.tv/%s) &nbsp; | %s\n", s.Name, s.Name, s.Lang)
-			}
-		} else { // offline
-			if s.YTURL != "" {
-				line = fmt.Sprintf("&nbsp; | `%s` | [<i class=\"fab fa-twitch\" style=\"color:#9146FF\"></i>](https://www.twitch.tv/%s) &nbsp; [<i class=\"fab fa-youtube\" style=\"color:#C00\"></i>](%s) |\n", s.Name, s.Name, s.YTURL)
-			} else {
-				line = fmt.Sprintf("&nbsp; | `%s` | [<i class=\"fab fa-twitch\" style=\"color:#9146FF\"></i>](https://www.twitch.tv/%s) &nbsp; |\n", s.Name, s.Name)
-			}
-		}
-	} else { // inactive streamers
-		if s.YTURL != "" {
-			line = fmt.Sprintf("`%s` | [<i class=\"fab fa-twitch\" style=\"color:#9146FF\"></i>](https://www.twitch.tv/%s) &nbsp; [<i class=\"fab fa-youtube\" style=\"color:#C00\"></i>](%s)\n", s.Name, s.Name, s.YTURL)
-		} else {
-			line = fmt.Sprintf("`%s` | [<i class=\"fab fa-twitch\" style=\"color:#9146FF\"></i>](https://www.twitch.tv/%s) &nbsp;\n", s.Name, s.Name)
-		}
-	}
-	return line, nil
-}
-
 // OpenCSV opens the CSV file and returns an Afero file object and/or error.
 func OpenCSV(file string) (afero.File, error) {
-	var AppFs = afero.NewOsFs()
-	f, err := AppFs.Open(file)
+	return OpenCSVWithFS(afero.NewOsFs(), file)
+}
+
+// OpenCSVWithFS opens the CSV file on the given filesystem.
+func OpenCSVWithFS(fileSystem afero.Fs, file string) (afero.File, error) {
+	f, err := fileSystem.Open(file)
 	if errors.Is(err, fs.ErrNotExist) {
 		return nil, err
 	}
 	return f, err
 }
 
+// ErrEmptyCSV is returned by ParseStreamers for a file that's empty or a directory.
+var ErrEmptyCSV = errors.New("file is not a file or is empty")
+
 // ParseStreamers takes an Afero file object and returns a StreamerList populated with Streamer objects.
 func ParseStreamers(f afero.File) (StreamerList, error) {
 	// Test if the file exists and is not a directory
@@ -264,13 +635,12 @@ func ParseStreamers(f afero.File) (StreamerList, error) {
 		if err != nil {
 			return sl, err
 		}
-		parsed, err := parseCSVData(string(b))
+		sl, err = parseCSVData(string(b))
 		if err != nil {
 			return sl, err
 		}
-		sl = parsed
 	} else {
-		return sl, errors.New("file is not a file or is empty")
+		return sl, ErrEmptyCSV
 	}
 	return sl, nil
 }
@@ -282,11 +652,15 @@ func (sl StreamerList) WriteCSV(filePath string) error {
 
 // WriteCSVWithFS writes the streamer list to a CSV file sorted by name using the provided filesystem.
 func (sl StreamerList) WriteCSVWithFS(fileSystem afero.Fs, filePath string) error {
+	return afero.WriteFile(fileSystem, filePath, sl.CSV(), 0644)
+}
+
+// CSV returns the streamer list as CSV file content sorted by name.
+func (sl StreamerList) CSV() []byte {
 	list := StreamerList{Streamers: append([]Streamer(nil), sl.Streamers...)}
 	list.SortByName()
 
-	content := buildCSVContent(list.Streamers)
-	return afero.WriteFile(fileSystem, filePath, []byte(content), 0644)
+	return []byte(buildCSVContent(list.Streamers))
 }
 
 // AppendToCSV adds a streamer to a CSV file and keeps it sorted by name.
@@ -322,6 +696,9 @@ func RemoveFromCSVWithFS(fileSystem afero.Fs, filePath string, streamer Streamer
 	return list.WriteCSVWithFS(fileSystem, filePath)
 }
 
+// buildCSVContent writes one streamer per line as 'name,youtube_url[,platform=handle...][,lang=tag][,tags=tag;tag...]'.
+// A streamer whose only other account is Twitch, under their name, is written in the legacy format. Anyone else gets
+// a column per account, a twitch one included, and a language seen live isn't written.
 func buildCSVContent(streamers []Streamer) string {
 	var builder strings.Builder
 	for _, s := range streamers {
@@ -332,9 +709,44 @@ func buildCSVContent(streamers []Streamer) string {
 		if builder.Len() > 0 {
 			builder.WriteByte('\n')
 		}
+		youtube := strings.TrimSpace(s.YouTubeURL())
+		var columns []string
+		for _, a := range s.Accounts {
+			if a.Platform == YouTube {
+				continue
+			}
+			value := a.Handle
+			if profileURL(a.Platform, a.Handle) != a.URL {
+				value = a.URL
+			}
+			columns = append(columns, string(a.Platform)+"="+value)
+		}
+		twitch, ok := s.Account(Twitch)
+		switch {
+		case ok && len(columns) == 1 && twitch.Handle == name && twitch.URL == profileURL(Twitch, name):
+			// The legacy format implies the Twitch account
+			columns = nil
+		case len(columns) == 0 && youtube != "":
+			// Without a platform column the line would be read as legacy, with a Twitch account
+			columns, youtube = []string{string(YouTube) + "=" + youtube}, ""
+		}
 		builder.WriteString(name)
 		builder.WriteByte(',')
-		builder.WriteString(strings.TrimSpace(s.YTURL))
+		builder.WriteString(youtube)
+		for _, c := range columns {
+			builder.WriteByte(',')
+			builder.WriteString(c)
+		}
+		if s.Lang != "" && s.LangFromCSV {
+			builder.WriteString(",lang=" + s.Lang)
+		}
+		if len(s.Tags) > 0 {
+			tags := make([]string, len(s.Tags))
+			for i, t := range s.Tags {
+				tags[i] = string(t)
+			}
+			builder.WriteString(",tags=" + strings.Join(tags, ";"))
+		}
 	}
 	return builder.String()
 }
@@ -354,6 +766,9 @@ func readCSVFile(fileSystem afero.Fs, filePath string) (StreamerList, error) {
 	return parseCSVData(string(data))
 }
 
+// parseCSVData parses every line of a CSV file, or returns an empty list if any line is wrong.
+// A line without platform columns is the legacy format, where the name is the streamer's Twitch handle.
+// Otherwise the columns list every account besides YouTube's, and Twitch needs a twitch column too.
 func parseCSVData(data string) (StreamerList, error) {
 	list := StreamerList{}
 	for _, line := range strings.Split(data, "\n") {
@@ -363,12 +778,93 @@ func parseCSVData(data string) (StreamerList, error) {
 		}
 		parts := strings.SplitN(line, ",", 2)
 		if len(parts) < 2 {
-			return list, fmt.Errorf("file is not a CSV file: Text: %s", data)
+			return StreamerList{}, fmt.Errorf("file is not a CSV file: Text: %s", data)
+		}
+		columns := splitColumns(parts[1])
+		s := Streamer{Name: parts[0]}
+		s.SetAccount(Account{Platform: YouTube, URL: strings.TrimSpace(columns[0])})
+		legacy := true
+		for _, field := range columns[1:] {
+			if err := s.parseField(field); err != nil {
+				return StreamerList{}, fmt.Errorf("streamer %s: %w", s.Name, err)
+			}
+			if key, _, _ := strings.Cut(field, "="); key != "lang" && key != "tags" {
+				legacy = false
+			}
 		}
-		list.Streamers = append(list.Streamers, Streamer{
-			Name:  parts[0],
-			YTURL: parts[1],
-		})
+		if legacy {
+			s.SetAccount(Account{Platform: Twitch, Handle: s.Name})
+		}
+		list.Streamers = append(list.Streamers, s)
 	}
 	return list, nil
 }
+
+// splitColumns splits what follows a line's name into the youtube url and the key=value columns.
+// A comma only starts a new column when a key= follows it, so a url or value with commas in it stays whole,
+// as the youtube url did when lines only had the two legacy columns.
+func splitColumns(rest string) []string {
+	var columns []string
+	for i, part := range strings.Split(rest, ",") {
+		if i > 0 && columns[len(columns)-1] != "" && !isColumn(part) {
+			columns[len(columns)-1] += "," + part
+			continue
+		}
+		columns = append(columns, part)
+	}
+	return columns
+}
+
+// isColumn reports whether text starts a key=value column.
+func isColumn(text string) bool {
+	key, _, ok := strings.Cut(strings.TrimSpace(text), "=")
+	if !ok || key == "" {
+		return false
+	}
+	for _, r := range key {
+		if (r < 'a' || r > 'z') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseField applies an extra 'key=value' CSV column to the streamer.
+// A platform key takes either a handle or a url; self-hosted platforms need a url.
+// The lang key takes a BCP 47 language tag and the tags key takes tags separated by semicolons.
+func (s *Streamer) parseField(field string) error {
+	field = strings.TrimSpace(field)
+	if field == "" {
+		return nil
+	}
+	key, value, ok := strings.Cut(field, "=")
+	if !ok {
+		return fmt.Errorf("column %q is not key=value", field)
+	}
+	value = strings.TrimSpace(value)
+	if key == "lang" {
+		lang, err := ParseLang(value)
+		s.Lang, s.LangFromCSV = lang, lang != ""
+		return err
+	}
+	if key == "tags" {
+		tags, err := parseTags(value)
+		s.Tags = tags
+		return err
+	}
+	p, ok := parsePlatform(key)
+	if !ok {
+		return fmt.Errorf("unknown column %q", key)
+	}
+	a := Account{Platform: p}
+	if strings.Contains(value, "://") {
+		a.URL = value
+	} else {
+		a.Handle = value
+	}
+	if a.URL == "" && profileURL(p, a.Handle) == "" {
+		return fmt.Errorf("%s account needs a url: %q", p, value)
+	}
+	s.SetAccount(a)
+	return nil
+}
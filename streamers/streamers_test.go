@@ -1,12 +1,17 @@
 package streamers_test
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/infosecstreams/secinfo/streamers"
 	"github.com/spf13/afero"
@@ -47,8 +52,8 @@ func TestParseStreamersPass(t *testing.T) {
 	}
 
 	// Test is the sixth streamer has the correct YTURL
-	if sl.Streamers[6].YTURL != "https://www.youtube.com/channel/UCQWQlNq07_Rumy2i69dpqBw" {
-		t.Errorf("Got: %s, Wanted: %s", sl.Streamers[6].YTURL, "https://www.youtube.com/channel/UCQWQlNq07_Rumy2i69dpqBw")
+	if sl.Streamers[6].YouTubeURL() != "https://www.youtube.com/channel/UCQWQlNq07_Rumy2i69dpqBw" {
+		t.Errorf("Got: %s, Wanted: %s", sl.Streamers[6].YouTubeURL(), "https://www.youtube.com/channel/UCQWQlNq07_Rumy2i69dpqBw")
 	}
 }
 
@@ -188,10 +193,10 @@ func TestGetStats(t *testing.T) {
 	sl := streamers.StreamerList{
 		Streamers: []streamers.Streamer{
 			{Name: "fak3us3r", ThirtyDayStats: -1, SullyGnomeID: ""},
-			{Name: "0xBufu", YTURL: "", SullyGnomeID: "36324233", ThirtyDayStats: 0},
-			{Name: "0xCardinal", YTURL: "", SullyGnomeID: "41037834", ThirtyDayStats: 0},
-			{Name: "0xChance", YTURL: "", SullyGnomeID: "5484638", ThirtyDayStats: 0},
-			{Name: "0xRy4nG", YTURL: "https://www.youtube.com/channel/UCQWQlNq07_Rumy2i69dpqBw", SullyGnomeID: "6445036", ThirtyDayStats: 0},
+			{Name: "0xBufu", SullyGnomeID: "36324233", ThirtyDayStats: 0},
+			{Name: "0xCardinal", SullyGnomeID: "41037834", ThirtyDayStats: 0},
+			{Name: "0xChance", SullyGnomeID: "5484638", ThirtyDayStats: 0},
+			{Name: "0xRy4nG", Accounts: []streamers.Account{{Platform: streamers.YouTube, URL: "https://www.youtube.com/channel/UCQWQlNq07_Rumy2i69dpqBw"}}, SullyGnomeID: "6445036", ThirtyDayStats: 0},
 		},
 	}
 
@@ -201,37 +206,47 @@ func TestGetStats(t *testing.T) {
 	}
 }
 
-func TestReturnMarkdownLine(t *testing.T) {
-	f, _ := AFS.Open("streamers.csv")
+func TestSullyGnomeProviderSettings(t *testing.T) {
+	var agents, paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents = append(agents, r.UserAgent())
+		paths = append(paths, r.URL.Path)
+		if strings.HasSuffix(r.URL.Path, "/activitystats") {
+			fmt.Fprint(w, `<span class="PageHeaderMiddleWithImageHeaderP1">Alice</span><script>var PageInfo = {"id":42};</script>`)
+			return
+		}
+		fmt.Fprint(w, `{"data":{"datasets":[{"data":[1,2]}]}}`)
+	}))
+	defer server.Close()
 
-	// Parse the streamers.csv file
-	sl, err := streamers.ParseStreamers(f)
+	sg := streamers.SullyGnome{BaseURL: server.URL + "/", UserAgent: "secinfo-test", WindowDays: 7}
+	s := streamers.Streamer{Name: "Alice"}
+	hours, err := sg.Hours(context.Background(), &s, streamers.Account{Platform: streamers.Twitch, Handle: "Alice"})
 	if err != nil {
-		t.Errorf("%s", err)
+		t.Fatalf("Hours failed: %v", err)
 	}
-	for _, s := range sl.Streamers {
-		s.GetUID()
-		s.GetStats()
-		if s.YTURL != "" && s.Name == "0xRy4nG" {
-			s.ThirtyDayStats = 0
-			s.ReturnMarkdownLine(true)
-			s.ReturnMarkdownLine(false)
-		} else if s.YTURL != "" && s.Name == "Security_Live" {
-			s.ThirtyDayStats = 20
-			s.ReturnMarkdownLine(true)
-			s.ReturnMarkdownLine(false)
+	if hours != 5 || s.SullyGnomeID != "42" {
+		t.Errorf("Got: %v hours and id %q, Wanted: 5 hours and id 42", hours, s.SullyGnomeID)
+	}
+	if s.TypicalStream() != 2 || len(s.StreamLengths) != 2 {
+		t.Errorf("Got: %v with a typical stream of %d hours, Wanted the histogram with 2 hours", s.StreamLengths, s.TypicalStream())
+	}
+	wantPaths := []string{"/channel/Alice/7/activitystats", "/api/charts/barcharts/getconfig/channelhourstreams/7/42/Alice/ / /0/0/ /0/0/"}
+	if strings.Join(paths, "\n") != strings.Join(wantPaths, "\n") {
+		t.Errorf("Got: %q, Wanted: %q", paths, wantPaths)
+	}
+	for _, agent := range agents {
+		if agent != "secinfo-test" {
+			t.Errorf("Got: %q, Wanted: secinfo-test", agent)
 		}
-		s.ReturnMarkdownLine(true)
-		s.ReturnMarkdownLine(false)
-		fmt.Printf("Object: %+v", s)
 	}
 }
 
 func TestWriteCSVSortsByName(t *testing.T) {
 	list := streamers.StreamerList{Streamers: []streamers.Streamer{
-		{Name: "bob", YTURL: ""},
-		{Name: "Alice", YTURL: ""},
-		{Name: "charlie", YTURL: ""},
+		{Name: "bob"},
+		{Name: "Alice"},
+		{Name: "charlie"},
 	}}
 
 	dir := t.TempDir()
@@ -296,3 +311,399 @@ func TestContainsRemoveStreamer(t *testing.T) {
 		t.Fatalf("RemoveStreamer should remove by name")
 	}
 }
+
+func TestParsePlatformColumns(t *testing.T) {
+	data := "alice,https://www.youtube.com/channel/UC123,twitch=alice,kick=alice_k,owncast=https://live.example.com,peertube=https://tube.example.com/c/alice\nbob,\ncarol,,kick=carol\ndave,,youtube=https://www.youtube.com/channel/UC456\nerin,https://www.youtube.com/watch?v=a,b,lang=de"
+	if err := afero.WriteFile(AFS, "platforms.csv", []byte(data), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	f, _ := AFS.Open("platforms.csv")
+	sl, err := streamers.ParseStreamers(f)
+	if err != nil {
+		t.Fatalf("ParseStreamers failed: %v", err)
+	}
+
+	var got []string
+	for _, a := range sl.Streamers[0].Accounts {
+		got = append(got, fmt.Sprintf("%s %s %s", a.Platform, a.Handle, a.URL))
+	}
+	want := []string{
+		"twitch alice https://www.twitch.tv/alice",
+		"youtube UC123 https://www.youtube.com/channel/UC123",
+		"kick alice_k https://kick.com/alice_k",
+		"owncast live.example.com https://live.example.com",
+		"peertube alice https://tube.example.com/c/alice",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Got: %q, Wanted: %q", got, want)
+	}
+	if len(sl.Streamers[1].Accounts) != 1 {
+		t.Fatalf("bob should only have a Twitch account: %+v", sl.Streamers[1].Accounts)
+	}
+	// Streamers that declare their platforms only have a Twitch account if they declare that too
+	for i, want := range []streamers.Platform{streamers.Kick, streamers.YouTube} {
+		if accounts := sl.Streamers[2+i].Accounts; len(accounts) != 1 || accounts[0].Platform != want {
+			t.Errorf("Got: %+v, Wanted only a %s account", accounts, want)
+		}
+	}
+	// A comma doesn't split the legacy youtube column unless a key=value column follows it
+	if got, want := sl.Streamers[4].YouTubeURL(), "https://www.youtube.com/watch?v=a,b"; got != want || sl.Streamers[4].Lang != "de" {
+		t.Errorf("Got: %q and %q, Wanted: %q and de", got, sl.Streamers[4].Lang, want)
+	}
+
+	filePath := filepath.Join(t.TempDir(), "streamers.csv")
+	if err := sl.WriteCSV(filePath); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	written, _ := os.ReadFile(filePath)
+	if string(written) != data {
+		t.Fatalf("Got: %q, Wanted: %q", written, data)
+	}
+}
+
+func TestParsePlatformColumnsFail(t *testing.T) {
+	for _, data := range []string{"alice,,myspace=alice", "alice,,kick", "alice,,owncast=alice", "bob,\nalice,,myspace=alice\ncarol,"} {
+		if err := afero.WriteFile(AFS, "bad_platforms.csv", []byte(data), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		f, _ := AFS.Open("bad_platforms.csv")
+		sl, err := streamers.ParseStreamers(f)
+		if err == nil {
+			t.Errorf("expected error parsing %q", data)
+		}
+		if sl.Len() != 0 {
+			t.Errorf("Got: %d, Wanted: %d streamers from a file with a bad line", sl.Len(), 0)
+		}
+	}
+}
+
+type fakeProvider struct {
+	platform streamers.Platform
+	hours    float32
+	err      error
+}
+
+func (p fakeProvider) Platform() streamers.Platform { return p.platform }
+
+func (p fakeProvider) Hours(ctx context.Context, s *streamers.Streamer, a streamers.Account) (float32, error) {
+	return p.hours, p.err
+}
+
+func TestCollectStatsAggregatesPlatforms(t *testing.T) {
+	s := streamers.Streamer{Name: "alice"}
+	s.SetAccount(streamers.Account{Platform: streamers.Twitch, Handle: "alice"})
+	s.SetAccount(streamers.Account{Platform: streamers.Kick, Handle: "alice"})
+	s.SetAccount(streamers.Account{Platform: streamers.YouTube, Handle: "alice"})
+
+	err := s.CollectStats(context.Background(),
+		fakeProvider{platform: streamers.Twitch, hours: 10},
+		fakeProvider{platform: streamers.Kick, hours: 2.5},
+		fakeProvider{platform: streamers.Owncast, hours: 100},
+	)
+	if err != nil {
+		t.Fatalf("CollectStats failed: %v", err)
+	}
+	if s.ThirtyDayStats != 12.5 {
+		t.Fatalf("Got: %v, Wanted: %v", s.ThirtyDayStats, 12.5)
+	}
+
+	err = s.CollectStats(context.Background(),
+		fakeProvider{platform: streamers.Twitch, err: errors.New("down")},
+		fakeProvider{platform: streamers.Kick, hours: 2.5},
+	)
+	if err == nil || !strings.Contains(err.Error(), "twitch: down") {
+		t.Fatalf("expected twitch error, got: %v", err)
+	}
+	if s.ThirtyDayStats != 2.5 {
+		t.Fatalf("Got: %v, Wanted: %v", s.ThirtyDayStats, 2.5)
+	}
+}
+
+func TestUnmarshalLegacyYTURL(t *testing.T) {
+	var s streamers.Streamer
+	if err := json.Unmarshal([]byte(`{"Name":"alice","YTURL":"https://www.youtube.com/channel/UC123"}`), &s); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if s.YouTubeURL() != "https://www.youtube.com/channel/UC123" {
+		t.Fatalf("Got: %q", s.YouTubeURL())
+	}
+	if a, ok := s.Account(streamers.Twitch); !ok || a.URL != "https://www.twitch.tv/alice" {
+		t.Fatalf("missing Twitch account: %+v", s.Accounts)
+	}
+}
+
+func TestNonTwitchStreamer(t *testing.T) {
+	var s streamers.Streamer
+	if err := json.Unmarshal([]byte(`{"Name":"carol","Accounts":[{"Platform":"kick","Handle":"carol","URL":"https://kick.com/carol"}]}`), &s); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if _, ok := s.Account(streamers.Twitch); ok {
+		t.Fatalf("Got: %+v, Wanted no Twitch account for a Kick streamer", s.Accounts)
+	}
+
+	// Only SullyGnome's Twitch stats are fetched, so a Kick streamer isn't looked up and counts no hours
+	calls := 0
+	if err := s.CollectStats(context.Background(), countingProvider{calls: &calls}); err != nil {
+		t.Fatalf("Got: %v, Wanted no failed lookup", err)
+	}
+	if calls != 0 || s.ThirtyDayStats != 0 {
+		t.Errorf("Got: %d lookups and %v hours, Wanted: 0 and 0", calls, s.ThirtyDayStats)
+	}
+}
+
+type countingProvider struct {
+	calls *int
+}
+
+func (countingProvider) Platform() streamers.Platform { return streamers.Twitch }
+
+func (p countingProvider) Hours(ctx context.Context, s *streamers.Streamer, a streamers.Account) (float32, error) {
+	*p.calls++
+	s.Name, s.SullyGnomeID = "Alice", "42"
+	return 7, nil
+}
+
+func TestStatsCacheFetchesOnce(t *testing.T) {
+	var calls int
+	var cache streamers.StatsCache
+	provider := cache.Provider(countingProvider{calls: &calls})
+
+	for _, name := range []string{"alice", "ALICE"} {
+		s := streamers.Streamer{Name: name}
+		s.SetAccount(streamers.Account{Platform: streamers.Twitch, Handle: name})
+		if err := s.CollectStats(context.Background(), provider); err != nil {
+			t.Fatalf("CollectStats failed: %v", err)
+		}
+		if s.ThirtyDayStats != 7 || s.Name != "Alice" || s.SullyGnomeID != "42" {
+			t.Errorf("Got: %+v, Wanted the first lookup's hours, name and id", s)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Got: %d lookups, Wanted: 1", calls)
+	}
+}
+
+func TestStatsCacheSkipsCanceledLookups(t *testing.T) {
+	var calls int
+	var cache streamers.StatsCache
+	provider := cache.Provider(countingProvider{calls: &calls})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, ctx := range []context.Context{ctx, context.Background(), context.Background()} {
+		s := streamers.Streamer{Name: "alice"}
+		s.SetAccount(streamers.Account{Platform: streamers.Twitch, Handle: "alice"})
+		s.CollectStats(ctx, provider)
+	}
+	if calls != 2 {
+		t.Errorf("Got: %d lookups, Wanted the canceled one not to be cached", calls)
+	}
+}
+
+func TestSullyGnomeCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	s := streamers.Streamer{Name: "alice"}
+	if _, err := (streamers.SullyGnome{BaseURL: server.URL}).Hours(ctx, &s, streamers.Account{Platform: streamers.Twitch, Handle: "alice"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Got: %v, Wanted: %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestParseTagsColumn(t *testing.T) {
+	f, _ := afero.TempFile(FS, "", "tags.csv")
+	f.WriteString("alice,,tags=Red Team;ctf\nbob,,kick=bob,tags=\n")
+	f.Seek(0, 0)
+
+	sl, err := streamers.ParseStreamers(f)
+	if err != nil {
+		t.Fatalf("ParseStreamers failed: %v", err)
+	}
+	if got := sl.Streamers[0].Tags; len(got) != 2 || got[0] != streamers.RedTeam || got[1] != streamers.CTF {
+		t.Errorf("Got: %q, Wanted: [red-team ctf]", got)
+	}
+	if len(sl.Streamers[1].Tags) != 0 {
+		t.Errorf("Got: %q, Wanted no tags", sl.Streamers[1].Tags)
+	}
+
+	want := "alice,,tags=red-team;ctf\nbob,,kick=bob"
+	if got := string(sl.CSV()); got != want {
+		t.Errorf("Got: %q, Wanted: %q", got, want)
+	}
+
+	f, _ = afero.TempFile(FS, "", "badtags.csv")
+	f.WriteString("alice,,tags=purple team\n")
+	f.Seek(0, 0)
+	if _, err := streamers.ParseStreamers(f); err == nil || !strings.Contains(err.Error(), "purple team") {
+		t.Errorf("Got: %v, Wanted an unknown tag error", err)
+	}
+}
+
+type fakeCategories struct {
+	category string
+}
+
+func (fakeCategories) Platform() streamers.Platform { return streamers.Twitch }
+
+func (p fakeCategories) TopCategory(ctx context.Context, s *streamers.Streamer, a streamers.Account) (string, error) {
+	return p.category, nil
+}
+
+func TestDeriveTags(t *testing.T) {
+	s := streamers.Streamer{Name: "alice", Tags: []streamers.Tag{streamers.CTF}}
+	s.SetAccount(streamers.Account{Platform: streamers.Twitch, Handle: "alice"})
+
+	if err := s.DeriveTags(context.Background(), streamers.DefaultCategoryTags, fakeCategories{"Software and Game Development"}); err != nil {
+		t.Fatalf("DeriveTags failed: %v", err)
+	}
+	if s.Category != "Software and Game Development" || !s.HasTag(streamers.Dev) {
+		t.Errorf("Got: %+v, Wanted the dev tag from the category", s)
+	}
+	if got := s.AllTags(); len(got) != 2 || got[0] != streamers.CTF || got[1] != streamers.Dev {
+		t.Errorf("Got: %q, Wanted: [ctf dev]", got)
+	}
+
+	if err := s.DeriveTags(context.Background(), streamers.DefaultCategoryTags, fakeCategories{"Just Chatting"}); err != nil {
+		t.Fatalf("DeriveTags failed: %v", err)
+	}
+	if s.HasTag(streamers.Dev) || !s.HasTag(streamers.CTF) {
+		t.Errorf("Got: %q, Wanted only the csv's tags", s.AllTags())
+	}
+}
+
+func TestSullyGnomeTopCategory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tables/channeltables/games/30/42/ /1/2/desc/0/100" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"data":[
+			{"gamesplayed":"Just Chatting|Just_Chatting|https://example.com/a.jpg","streamtime":60},
+			{"gamesplayed":"Software and Game Development|Software_and_Game_Development|https://example.com/b.jpg","streamtime":600}
+		]}`)
+	}))
+	defer server.Close()
+
+	s := streamers.Streamer{Name: "alice", SullyGnomeID: "42"}
+	got, err := streamers.SullyGnome{BaseURL: server.URL}.TopCategory(context.Background(), &s, streamers.Account{Platform: streamers.Twitch, Handle: "alice"})
+	if err != nil {
+		t.Fatalf("TopCategory failed: %v", err)
+	}
+	if got != "Software and Game Development" {
+		t.Errorf("Got: %q, Wanted: Software and Game Development", got)
+	}
+}
+
+func TestParseLang(t *testing.T) {
+	for in, want := range map[string]string{"EN": "en", "pt_br": "pt-BR", " de-DE ": "de-DE", "es-419": "es-419"} {
+		if got, err := streamers.ParseLang(in); err != nil || got != want {
+			t.Errorf("Got: %q, %v, Wanted: %q for %q", got, err, want, in)
+		}
+	}
+	if _, err := streamers.ParseLang("english please"); err == nil {
+		t.Errorf("Got no error, Wanted one for an invalid tag")
+	}
+	if got := streamers.LangBase("pt-BR"); got != "pt" {
+		t.Errorf("Got: %q, Wanted: pt", got)
+	}
+	if got := streamers.LangName("de"); got != "Deutsch" {
+		t.Errorf("Got: %q, Wanted: Deutsch", got)
+	}
+}
+
+func TestLangColumn(t *testing.T) {
+	f, _ := afero.TempFile(FS, "", "lang.csv")
+	f.WriteString("alice,,lang=PT_br,tags=ctf\nbob,\ncarol,,lang=pt\n")
+	f.Seek(0, 0)
+
+	sl, err := streamers.ParseStreamers(f)
+	if err != nil {
+		t.Fatalf("ParseStreamers failed: %v", err)
+	}
+	if sl.Streamers[0].Lang != "pt-BR" || sl.Streamers[1].Lang != "" {
+		t.Errorf("Got: %q and %q, Wanted: pt-BR and none", sl.Streamers[0].Lang, sl.Streamers[1].Lang)
+	}
+	want := "alice,,lang=pt-BR,tags=ctf\nbob,\ncarol,,lang=pt"
+	if got := string(sl.CSV()); got != want {
+		t.Errorf("Got: %q, Wanted: %q", got, want)
+	}
+
+	// A language picked up while live isn't written to the csv
+	if !sl.Streamers[1].OnlineNow("| 🟢 | `bob` | de") || sl.Streamers[1].Lang != "de" {
+		t.Errorf("Got: %q, Wanted: de from the index", sl.Streamers[1].Lang)
+	}
+	if got := string(sl.CSV()); got != want {
+		t.Errorf("Got: %q, Wanted: %q", got, want)
+	}
+
+	portuguese := sl.InLanguage("pt-PT")
+	if len(portuguese.Streamers) != 2 || portuguese.Streamers[0].Name != "alice" || portuguese.Streamers[1].Name != "carol" {
+		t.Errorf("Got: %+v, Wanted alice and carol", portuguese.Streamers)
+	}
+
+	f, _ = afero.TempFile(FS, "", "badlang.csv")
+	f.WriteString("alice,,lang=english please\n")
+	f.Seek(0, 0)
+	if _, err := streamers.ParseStreamers(f); err == nil {
+		t.Errorf("Got no error, Wanted one for an invalid language")
+	}
+}
+
+func TestOnlineNowLanguage(t *testing.T) {
+	index := "🟢 | `alice` | links | | ES\n🟢 | `bob` | links | | ??\n🟢 | `carol` | links | | de"
+
+	alice, bob, carol := streamers.Streamer{Name: "alice"}, streamers.Streamer{Name: "bob"}, streamers.Streamer{Name: "carol", Lang: "en"}
+	for _, s := range []*streamers.Streamer{&alice, &bob, &carol} {
+		if !s.OnlineNow(index) {
+			t.Errorf("%s should be online", s.Name)
+		}
+	}
+	if alice.Lang != "es" || bob.Lang != "" || carol.Lang != "en" {
+		t.Errorf("Got: %q, %q, %q, Wanted: es, none, and en kept from the csv", alice.Lang, bob.Lang, carol.Lang)
+	}
+}
+
+func sortedNames(sl streamers.StreamerList) []string {
+	var got []string
+	for _, s := range sl.Streamers {
+		got = append(got, s.Name)
+	}
+	return got
+}
+
+func TestOrders(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 0, 0, 0, 0, time.UTC) }
+	sl := streamers.StreamerList{Streamers: []streamers.Streamer{
+		{Name: "fred", ThirtyDayStats: 5, Added: day(1)},
+		{Name: "Émile", ThirtyDayStats: 5, LastLive: day(10)},
+		{Name: "eli", ThirtyDayStats: 9, LastLive: day(10), Added: day(12)},
+		{Name: "Zoë", ThirtyDayStats: 1, LastLive: day(18), Added: day(12)},
+		{Name: "Eli", ThirtyDayStats: 5},
+	}}
+
+	for order, want := range map[string][]string{
+		"hours":  {"eli", "Eli", "Émile", "fred", "Zoë"},
+		"name":   {"Eli", "eli", "Émile", "fred", "Zoë"},
+		"live":   {"Zoë", "eli", "Émile", "Eli", "fred"},
+		"newest": {"eli", "Zoë", "fred", "Eli", "Émile"},
+	} {
+		got := sortedNames(sl.Sorted(streamers.Orders[order]))
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("%s: Got: %q, Wanted: %q", order, got, want)
+		}
+	}
+	if sl.Streamers[0].Name != "fred" {
+		t.Errorf("Sorted should leave the list as it was")
+	}
+
+	// Ties always come out the same way, whatever order they went in
+	reversed := streamers.StreamerList{Streamers: []streamers.Streamer{sl.Streamers[4], sl.Streamers[3], sl.Streamers[2], sl.Streamers[1], sl.Streamers[0]}}
+	reversed.Sort()
+	if got := sortedNames(reversed); strings.Join(got, ",") != "eli,Eli,Émile,fred,Zoë" {
+		t.Errorf("Got: %q, Wanted ties broken by name", got)
+	}
+}
@@ -0,0 +1,179 @@
+package streamers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Tag is a topic a streamer covers.
+type Tag string
+
+// Known tags, in the order they're rendered.
+const (
+	RedTeam  Tag = "red-team"
+	BlueTeam Tag = "blue-team"
+	CTF      Tag = "ctf"
+	Malware  Tag = "malware"
+	AppSec   Tag = "appsec"
+	Dev      Tag = "dev"
+	Hardware Tag = "hardware"
+)
+
+// Tags lists every Tag in the order they're rendered.
+var Tags = []Tag{RedTeam, BlueTeam, CTF, Malware, AppSec, Dev, Hardware}
+
+var tagTitles = map[Tag]string{
+	RedTeam:  "Red Team",
+	BlueTeam: "Blue Team",
+	CTF:      "CTF",
+	Malware:  "Malware",
+	AppSec:   "AppSec",
+	Dev:      "Dev",
+	Hardware: "Hardware",
+}
+
+// Title returns the tag's display name, e.g. "Red Team".
+func (t Tag) Title() string {
+	if title, ok := tagTitles[t]; ok {
+		return title
+	}
+	return string(t)
+}
+
+// ParseTag returns the Tag named by s, ignoring case and treating spaces and underscores as dashes, e.g. "Red Team".
+func ParseTag(s string) (Tag, bool) {
+	name := strings.NewReplacer(" ", "-", "_", "-").Replace(strings.ToLower(strings.TrimSpace(s)))
+	for _, t := range Tags {
+		if string(t) == name {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+// DefaultCategoryTags maps the Twitch categories that clearly belong to a topic to its Tag.
+var DefaultCategoryTags = map[string]Tag{
+	"Software and Game Development": Dev,
+	"Makers & Crafting":             Hardware,
+}
+
+// CategoryProvider reports the category a streamer streams in most on a single platform.
+type CategoryProvider interface {
+	Platform() Platform                                                      // The platform the provider has categories for
+	TopCategory(ctx context.Context, s *Streamer, a Account) (string, error) // The category a streamed in most, empty if unknown
+}
+
+// DeriveTags sets Category to the most used category the providers report for the streamer's accounts,
+// and DerivedTags to the tag it maps to in mapping, if any. The first account with a category wins.
+func (s *Streamer) DeriveTags(ctx context.Context, mapping map[string]Tag, providers ...CategoryProvider) error {
+	var errs []error
+	for _, a := range s.Accounts {
+		for _, p := range providers {
+			if p.Platform() != a.Platform {
+				continue
+			}
+			category, err := p.TopCategory(ctx, s, a)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", a.Platform, err))
+				continue
+			}
+			if category == "" {
+				continue
+			}
+			s.Category, s.DerivedTags = category, nil
+			if t, ok := mapping[category]; ok {
+				s.DerivedTags = []Tag{t}
+			}
+			return nil
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// AllTags returns the streamer's tags and derived tags without duplicates, in the order of Tags.
+func (s Streamer) AllTags() []Tag {
+	var all []Tag
+	for _, t := range Tags {
+		for _, have := range append(append([]Tag(nil), s.Tags...), s.DerivedTags...) {
+			if have == t {
+				all = append(all, t)
+				break
+			}
+		}
+	}
+	return all
+}
+
+// HasTag reports whether t is one of the streamer's tags or derived tags.
+func (s Streamer) HasTag(t Tag) bool {
+	for _, have := range s.AllTags() {
+		if have == t {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTags parses the value of a 'tags=' CSV column, tags separated by semicolons.
+func parseTags(value string) ([]Tag, error) {
+	var tags []Tag
+	for _, name := range strings.Split(value, ";") {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		t, ok := ParseTag(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown tag %q", name)
+		}
+		tags = append(tags, t)
+	}
+	return Streamer{Tags: tags}.AllTags(), nil
+}
+
+// TopCategory returns the Twitch category the streamer streamed in most during the window, by stream time.
+func (sg SullyGnome) TopCategory(ctx context.Context, s *Streamer, a Account) (string, error) {
+	if s.SullyGnomeID == "" {
+		if err := sg.lookupUID(ctx, s); err != nil {
+			return "", err
+		}
+	}
+
+	// The URL is f'https://sullygnome.com/api/tables/channeltables/games/30/{uid}/%20/1/2/desc/0/100'
+	request, err := http.NewRequestWithContext(ctx, "GET", sg.baseURL()+"/api/tables/channeltables/games/"+strconv.Itoa(sg.windowDays())+"/"+s.SullyGnomeID+"/%20/1/2/desc/0/100", nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %w", err)
+	}
+	r, err := sg.do(request)
+	if err != nil {
+		return "", fmt.Errorf("error sending categories request for %s: %w", s.Name, err)
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error fetching categories for %s: %s", s.Name, r.Status)
+	}
+
+	// Each game is 'name|slug|image url' with the minutes streamed in it
+	var games struct {
+		Data []struct {
+			GamesPlayed string  `json:"gamesplayed"`
+			StreamTime  float64 `json:"streamtime"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&games); err != nil {
+		return "", fmt.Errorf("error decoding categories response for %s: %w", s.Name, err)
+	}
+	var top string
+	var most float64
+	for _, g := range games.Data {
+		name, _, _ := strings.Cut(g.GamesPlayed, "|")
+		if name != "" && g.StreamTime > most {
+			top, most = name, g.StreamTime
+		}
+	}
+	return top, nil
+}
@@ -0,0 +1,216 @@
+/*
+Package sullygnometest runs a fake SullyGnome for tests, serving the pages and API responses secinfo fetches for
+a set of fake channels, with failures injected per channel.
+
+Point secinfo at it with the sullygnome_url setting, SECINFO_SULLYGNOME_URL or streamers.SullyGnome's BaseURL.
+*/
+package sullygnometest
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDelay is how long a Slow channel's responses take if the server's Delay isn't set.
+const DefaultDelay = 2 * time.Second
+
+// Failure is a way a channel's responses fail.
+type Failure string
+
+// The failures a channel can be set up with.
+const (
+	NotFound      Failure = "404"            // Every request about the channel is a 404, like a channel SullyGnome doesn't know
+	ServerError   Failure = "500"            // Every request about the channel is a 500
+	MalformedHTML Failure = "malformed-html" // The activitystats page has the channel's name but no PageInfo
+	EmptyDatasets Failure = "empty-datasets" // The channelhourstreams chart has no datasets
+	Slow          Failure = "slow"           // Every response waits for the server's Delay first
+)
+
+// Game is a category a channel streamed in.
+type Game struct {
+	Name    string  // The category, e.g. "Science & Technology"
+	Minutes float64 // How long the channel streamed in it
+}
+
+// Channel is a fake channel.
+type Channel struct {
+	Name          string    // The channel's display name, lookups match it case-insensitively
+	ID            int       // The channel's SullyGnome id, assigned by the server if zero
+	StreamLengths []float32 // How many 1, 2, 3... hour streams the channel had, 1 hour = index 0
+	Games         []Game    // The categories the channel streamed in
+	Failure       Failure   // How the channel's responses fail, none if empty
+}
+
+// Server is a fake SullyGnome listening on a local port.
+type Server struct {
+	*httptest.Server
+	Delay time.Duration // How long Slow responses wait, DefaultDelay if zero
+
+	mu       sync.Mutex
+	channels map[string]*Channel // By lower case name
+	byID     map[string]*Channel
+	nextID   int // The id the next channel without one gets
+	requests []string
+}
+
+// NewServer starts a fake SullyGnome with the channels. Close it when done.
+func NewServer(channels ...Channel) *Server {
+	s := &Server{channels: map[string]*Channel{}, byID: map[string]*Channel{}, nextID: 1000}
+	for _, c := range channels {
+		s.Add(c)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /channel/{name}/{days}/activitystats", s.activityStats)
+	mux.HandleFunc("GET /api/charts/barcharts/getconfig/channelhourstreams/{days}/{id}/{rest...}", s.hourStreams)
+	mux.HandleFunc("GET /api/tables/channeltables/games/{days}/{id}/{rest...}", s.games)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.URL.Path)
+		s.mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	return s
+}
+
+// Add adds a channel, or replaces the one with the same name, which keeps its id unless c has one.
+// Channels without an id get the next one no other channel has.
+func (s *Server) Add(c Channel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.channels[strings.ToLower(c.Name)]; ok {
+		delete(s.byID, strconv.Itoa(old.ID))
+		if c.ID == 0 {
+			c.ID = old.ID
+		}
+	}
+	for c.ID == 0 {
+		if _, taken := s.byID[strconv.Itoa(s.nextID)]; !taken {
+			c.ID = s.nextID
+		}
+		s.nextID++
+	}
+	s.channels[strings.ToLower(c.Name)] = &c
+	s.byID[strconv.Itoa(c.ID)] = &c
+}
+
+// Fail sets how the named channel's responses fail, or makes them succeed again with an empty Failure.
+func (s *Server) Fail(name string, f Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.channels[strings.ToLower(name)]; ok {
+		c.Failure = f
+	}
+}
+
+// Requests returns the paths requested so far, in order.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// channel returns a copy of the channel found by find, after failing the request if the channel is set up to.
+// ok is false if the response has been written.
+func (s *Server) channel(w http.ResponseWriter, r *http.Request, find func() *Channel) (Channel, bool) {
+	s.mu.Lock()
+	found := find()
+	delay := s.Delay
+	s.mu.Unlock()
+	if found == nil {
+		http.NotFound(w, r)
+		return Channel{}, false
+	}
+	c := *found
+
+	switch c.Failure {
+	case NotFound:
+		http.NotFound(w, r)
+		return c, false
+	case ServerError:
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return c, false
+	case Slow:
+		if delay == 0 {
+			delay = DefaultDelay
+		}
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return c, false
+		}
+	}
+	return c, true
+}
+
+// activityStats serves a channel's page, which has its display name in the header and its id in PageInfo.
+func (s *Server) activityStats(w http.ResponseWriter, r *http.Request) {
+	c, ok := s.channel(w, r, func() *Channel { return s.channels[strings.ToLower(r.PathValue("name"))] })
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	requested := html.EscapeString(r.PathValue("name"))
+	fmt.Fprintf(w, `<!DOCTYPE html><html><head><title>%s - Twitch stats</title><link rel="canonical" href="/channel/%s/%s/activitystats"></head><body>`,
+		html.EscapeString(c.Name), requested, html.EscapeString(r.PathValue("days")))
+	fmt.Fprintf(w, `<span class="PageHeaderMiddleWithImageHeaderP1">%s</span>`, html.EscapeString(c.Name))
+	if c.Failure == MalformedHTML {
+		fmt.Fprint(w, `<script>var PageInf`)
+		return
+	}
+	pageInfo, _ := json.Marshal(map[string]any{"id": c.ID, "pageType": "channel"})
+	fmt.Fprintf(w, `<script>var PageInfo = %s;</script></body></html>`, pageInfo)
+}
+
+// hourStreams serves a channel's streams by length chart.
+func (s *Server) hourStreams(w http.ResponseWriter, r *http.Request) {
+	c, ok := s.channel(w, r, func() *Channel { return s.byID[r.PathValue("id")] })
+	if !ok {
+		return
+	}
+	type dataset struct {
+		Data []float32 `json:"data"`
+	}
+	var chart struct {
+		Data struct {
+			Datasets []dataset `json:"datasets"`
+		} `json:"data"`
+	}
+	chart.Data.Datasets = []dataset{}
+	if c.Failure != EmptyDatasets {
+		chart.Data.Datasets = append(chart.Data.Datasets, dataset{Data: append([]float32{}, c.StreamLengths...)})
+	}
+	writeJSON(w, chart)
+}
+
+// games serves a channel's table of categories, most streamed first.
+func (s *Server) games(w http.ResponseWriter, r *http.Request) {
+	c, ok := s.channel(w, r, func() *Channel { return s.byID[r.PathValue("id")] })
+	if !ok {
+		return
+	}
+	type row struct {
+		GamesPlayed string  `json:"gamesplayed"`
+		StreamTime  float64 `json:"streamtime"`
+	}
+	table := struct {
+		Data []row `json:"data"`
+	}{Data: []row{}}
+	for _, g := range c.Games {
+		slug := strings.ToLower(strings.ReplaceAll(g.Name, " ", "_"))
+		table.Data = append(table.Data, row{GamesPlayed: g.Name + "|" + slug + "|/images/" + slug + ".jpg", StreamTime: g.Minutes})
+	}
+	writeJSON(w, table)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(v)
+}
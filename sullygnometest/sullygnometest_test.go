@@ -0,0 +1,93 @@
+package sullygnometest_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/infosecstreams/secinfo/streamers"
+	"github.com/infosecstreams/secinfo/sullygnometest"
+)
+
+func TestServer(t *testing.T) {
+	server := sullygnometest.NewServer(sullygnometest.Channel{
+		Name:          "Alice",
+		ID:            42,
+		StreamLengths: []float32{2, 3, 1},
+		Games:         []sullygnometest.Game{{Name: "Just Chatting", Minutes: 60}, {Name: "Science & Technology", Minutes: 600}},
+	})
+	defer server.Close()
+	sg := streamers.SullyGnome{BaseURL: server.URL}
+
+	s := streamers.Streamer{Name: "alice"}
+	hours, err := sg.Hours(context.Background(), &s, streamers.Account{Platform: streamers.Twitch, Handle: "alice"})
+	if err != nil {
+		t.Fatalf("Hours failed: %v", err)
+	}
+	if hours != 11 || s.SullyGnomeID != "42" || s.Name != "Alice" {
+		t.Errorf("Got: %v hours for %s (%s), Wanted: 11 hours for Alice (42)", hours, s.Name, s.SullyGnomeID)
+	}
+	category, err := sg.TopCategory(context.Background(), &s, streamers.Account{Platform: streamers.Twitch, Handle: "Alice"})
+	if err != nil || category != "Science & Technology" {
+		t.Errorf("Got: %q, %v, Wanted: Science & Technology", category, err)
+	}
+	if got := len(server.Requests()); got != 3 {
+		t.Errorf("Got: %d requests, Wanted: 3", got)
+	}
+
+	if _, err := sg.Hours(context.Background(), &streamers.Streamer{Name: "nobody"}, streamers.Account{}); err == nil || !strings.Contains(err.Error(), "username not found") {
+		t.Errorf("Got: %v, Wanted unknown channels to be not found", err)
+	}
+}
+
+func TestFailures(t *testing.T) {
+	server := sullygnometest.NewServer()
+	defer server.Close()
+	server.Delay = time.Second
+	sg := streamers.SullyGnome{BaseURL: server.URL, Client: &http.Client{Timeout: 100 * time.Millisecond}}
+
+	for failure, want := range map[sullygnometest.Failure]string{
+		sullygnometest.NotFound:      "username not found",
+		sullygnometest.ServerError:   "500 Internal Server Error",
+		sullygnometest.MalformedHTML: "no PageInfo",
+		sullygnometest.EmptyDatasets: "no datasets",
+		sullygnometest.Slow:          "Client.Timeout",
+	} {
+		name := "chan-" + string(failure)
+		server.Add(sullygnometest.Channel{Name: name, StreamLengths: []float32{1}, Failure: failure})
+		if _, err := sg.Hours(context.Background(), &streamers.Streamer{Name: name}, streamers.Account{}); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: Got: %v, Wanted an error with %q", failure, err, want)
+		}
+	}
+
+	// Failures can be cleared, and the ids of channels stay the same
+	server.Fail("chan-500", "")
+	s := streamers.Streamer{Name: "chan-500"}
+	if hours, err := sg.Hours(context.Background(), &s, streamers.Account{}); err != nil || hours != 1 {
+		t.Errorf("Got: %v, %v, Wanted: 1 hour once the failure is cleared", hours, err)
+	}
+	server.Fail("chan-500", sullygnometest.ServerError)
+	if _, err := sg.Hours(context.Background(), &s, streamers.Account{}); err == nil || !strings.Contains(err.Error(), "error fetching stats") {
+		t.Errorf("Got: %v, Wanted the stats request to fail", err)
+	}
+}
+
+func TestAddIDs(t *testing.T) {
+	server := sullygnometest.NewServer(sullygnometest.Channel{Name: "alice"}, sullygnometest.Channel{Name: "bob", ID: 1001})
+	defer server.Close()
+	server.Add(sullygnometest.Channel{Name: "alice", ID: 7})
+	server.Add(sullygnometest.Channel{Name: "carol"})
+
+	for id, want := range map[string]int{"1000": http.StatusNotFound, "7": http.StatusOK, "1001": http.StatusOK, "1002": http.StatusOK} {
+		r, err := http.Get(server.URL + "/api/charts/barcharts/getconfig/channelhourstreams/30/" + id + "/x")
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		r.Body.Close()
+		if r.StatusCode != want {
+			t.Errorf("Got: %d, Wanted: %d for id %s", r.StatusCode, want, id)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+/*
+Package summary reports what a run changed as markdown, for the GitHub Actions job summary and
+the description of the pull request that commits the run's changes.
+*/
+package summary
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/infosecstreams/secinfo/changes"
+	"github.com/infosecstreams/secinfo/streamers"
+	"github.com/spf13/afero"
+)
+
+// DefaultMovers is how many rank changes a Report lists.
+const DefaultMovers = 5
+
+// Failure is a streamer whose stats couldn't be fetched.
+type Failure struct {
+	Name string // The streamer
+	Err  error  // Why the fetch failed
+}
+
+// Mover is an active streamer whose rank changed since the last run. Ranks start at 1.
+type Mover struct {
+	Name     string
+	From, To int
+}
+
+// Report summarises a run of one list.
+type Report struct {
+	List              string          // The list's name, empty when a run has a single list
+	Promoted, Demoted []changes.Event // Streamers that moved between the lists
+	Added, Removed    []changes.Event // Streamers new to, or gone from, the csv files
+	Failures          []Failure       // Streamers whose stats couldn't be fetched
+	KeptPrevious      bool            // Whether the Failures kept their stats from the last run because too many lookups failed
+	Stopped           string          // Why the run stopped without writing the list, empty if it was written
+	Warnings          []string        // Problems that didn't fail the run, like a schedule that couldn't be fetched
+	Movers            []Mover         // The biggest rank changes on the active list, biggest first
+	Active, Inactive  int             // How many streamers are on each list
+	Hours             float32         // Hours streamed by the active list
+	WindowDays        int             // Days of activity the hours cover
+	GeneratedAt       time.Time
+}
+
+// New builds the Report of the run that went from prev to cur with the given events and failures.
+func New(prev, cur changes.Run, events []changes.Event, failures []Failure, now time.Time) Report {
+	r := Report{
+		Promoted:    changes.Filter(events, changes.Promoted),
+		Demoted:     changes.Filter(events, changes.Demoted),
+		Added:       changes.Filter(events, changes.Added),
+		Removed:     changes.Filter(events, changes.Removed),
+		Failures:    failures,
+		Movers:      movers(prev.Active, cur.Active, DefaultMovers),
+		Active:      len(cur.Active),
+		Inactive:    len(cur.Inactive),
+		WindowDays:  streamers.WindowDays,
+		GeneratedAt: now,
+	}
+	for _, s := range cur.Active {
+		r.Hours += s.ThirtyDayStats
+	}
+	return r
+}
+
+// movers returns up to n streamers on both active lists whose rank changed the most, ties by name.
+func movers(prev, cur []streamers.Streamer, n int) []Mover {
+	ranks := make(map[string]int, len(prev))
+	for i, s := range prev {
+		ranks[strings.ToLower(s.Name)] = i + 1
+	}
+	var moved []Mover
+	for i, s := range cur {
+		if from, ok := ranks[strings.ToLower(s.Name)]; ok && from != i+1 {
+			moved = append(moved, Mover{Name: s.Name, From: from, To: i + 1})
+		}
+	}
+	sort.SliceStable(moved, func(i, j int) bool {
+		di, dj := abs(moved[i].From-moved[i].To), abs(moved[j].From-moved[j].To)
+		if di != dj {
+			return di > dj
+		}
+		return strings.ToLower(moved[i].Name) < strings.ToLower(moved[j].Name)
+	})
+	if len(moved) > n {
+		moved = moved[:n]
+	}
+	return moved
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Markdown renders the Report for the GitHub Actions job summary.
+func (r Report) Markdown() []byte {
+	var b bytes.Buffer
+	b.WriteString("## secinfo run ")
+	if r.List != "" {
+		fmt.Fprintf(&b, "of %s ", r.List)
+	}
+	fmt.Fprintf(&b, "%s\n\n", r.GeneratedAt.UTC().Format("2006-01-02 15:04 MST"))
+	r.sections(&b)
+	return b.Bytes()
+}
+
+// PullRequest renders the Report as the description of a pull request committing the run's changes.
+func (r Report) PullRequest() []byte {
+	var b bytes.Buffer
+	list := "streamer lists"
+	if r.List != "" {
+		list = r.List + " " + list
+	}
+	fmt.Fprintf(&b, "Automated update of the %s from the last %d days of streaming activity.\n\n", list, r.WindowDays)
+	r.sections(&b)
+	return b.Bytes()
+}
+
+func (r Report) sections(b *bytes.Buffer) {
+	if r.Stopped != "" {
+		fmt.Fprintf(b, "**Nothing was written**, the files from the last run are left as they were: %s\n\n", r.Stopped)
+	}
+	b.WriteString("### Totals\n\n")
+	b.WriteString("| Active | Inactive | Hours streamed | Promoted | Demoted | Added | Removed | Fetch failures |\n")
+	b.WriteString("| ---: | ---: | ---: | ---: | ---: | ---: | ---: | ---: |\n")
+	fmt.Fprintf(b, "| %d | %d | %.1f | %d | %d | %d | %d | %d |\n\n",
+		r.Active, r.Inactive, r.Hours, len(r.Promoted), len(r.Demoted), len(r.Added), len(r.Removed), len(r.Failures))
+
+	if len(r.Promoted) > 0 {
+		b.WriteString("### Promoted to active\n\n")
+		for _, e := range r.Promoted {
+			fmt.Fprintf(b, "- **%s** streamed %.1f hours\n", cell(e.Streamer.Name), e.Streamer.ThirtyDayStats)
+		}
+		b.WriteString("\n")
+	}
+	if len(r.Demoted) > 0 {
+		b.WriteString("### Demoted to inactive\n\n")
+		for _, e := range r.Demoted {
+			fmt.Fprintf(b, "- **%s** streamed %.1f hours, down from %.1f\n", cell(e.Streamer.Name), e.Streamer.ThirtyDayStats, e.Previous.ThirtyDayStats)
+		}
+		b.WriteString("\n")
+	}
+	if len(r.Added)+len(r.Removed) > 0 {
+		b.WriteString("### Added and removed\n\n")
+		for _, e := range r.Added {
+			fmt.Fprintf(b, "- Added **%s**\n", cell(e.Streamer.Name))
+		}
+		for _, e := range r.Removed {
+			fmt.Fprintf(b, "- Removed **%s**\n", cell(e.Streamer.Name))
+		}
+		b.WriteString("\n")
+	}
+	if len(r.Movers) > 0 {
+		b.WriteString("### Top movers\n\n| Streamer | Rank | Change |\n| --- | ---: | ---: |\n")
+		for _, m := range r.Movers {
+			arrow := "▲"
+			if m.To > m.From {
+				arrow = "▼"
+			}
+			fmt.Fprintf(b, "| %s | %d → %d | %s %d |\n", cell(m.Name), m.From, m.To, arrow, abs(m.From-m.To))
+		}
+		b.WriteString("\n")
+	}
+	if len(r.Failures) > 0 {
+		b.WriteString("### Fetch failures\n\n")
+		if r.Stopped != "" {
+			b.WriteString("Too many lookups failed, so none of the list was updated.\n\n")
+		} else if r.KeptPrevious {
+			b.WriteString("Too many lookups failed, so these streamers kept their hours from the last run.\n\n")
+		} else {
+			b.WriteString("These streamers kept whatever hours could be fetched, which may have moved them to the inactive list.\n\n")
+		}
+		b.WriteString("| Streamer | Reason |\n| --- | --- |\n")
+		for _, f := range r.Failures {
+			fmt.Fprintf(b, "| %s | %s |\n", cell(f.Name), cell(f.Err.Error()))
+		}
+		b.WriteString("\n")
+	}
+	if len(r.Warnings) > 0 {
+		b.WriteString("### Warnings\n\n")
+		for _, w := range r.Warnings {
+			fmt.Fprintf(b, "- %s\n", strings.Join(strings.Fields(w), " "))
+		}
+		b.WriteString("\n")
+	}
+}
+
+// cell keeps text from breaking out of a markdown table row.
+func cell(text string) string {
+	text = strings.ReplaceAll(text, "|", `\|`)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// Append adds data to the end of the file at path, creating it if needed, the way
+// $GITHUB_STEP_SUMMARY expects every step to add to it.
+func Append(fileSystem afero.Fs, path string, data []byte) error {
+	f, err := fileSystem.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
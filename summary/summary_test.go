@@ -0,0 +1,117 @@
+package summary_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/infosecstreams/secinfo/changes"
+	"github.com/infosecstreams/secinfo/streamers"
+	"github.com/infosecstreams/secinfo/summary"
+	"github.com/spf13/afero"
+)
+
+var now = time.Date(2026, 10, 19, 6, 0, 0, 0, time.UTC)
+
+func run() (changes.Run, changes.Run) {
+	prev := changes.Run{
+		Active: []streamers.Streamer{
+			{Name: "alice", ThirtyDayStats: 90},
+			{Name: "bob", ThirtyDayStats: 80},
+			{Name: "carol", ThirtyDayStats: 70},
+			{Name: "dave", ThirtyDayStats: 60},
+		},
+		Inactive: []streamers.Streamer{{Name: "erin"}},
+	}
+	cur := changes.Run{
+		Active: []streamers.Streamer{
+			{Name: "dave", ThirtyDayStats: 100},
+			{Name: "erin", ThirtyDayStats: 50},
+			{Name: "alice", ThirtyDayStats: 40},
+			{Name: "bob", ThirtyDayStats: 30},
+		},
+		Inactive: []streamers.Streamer{{Name: "carol"}},
+	}
+	return prev, cur
+}
+
+func TestNew(t *testing.T) {
+	prev, cur := run()
+	r := summary.New(prev, cur, changes.Diff(prev, cur), nil, now)
+
+	if r.Active != 4 || r.Inactive != 1 || r.Hours != 220 {
+		t.Errorf("Got: %d active, %d inactive, %v hours, Wanted: 4, 1, 220", r.Active, r.Inactive, r.Hours)
+	}
+	if len(r.Promoted) != 1 || r.Promoted[0].Streamer.Name != "erin" {
+		t.Errorf("Got: %v, Wanted: erin promoted", r.Promoted)
+	}
+	if len(r.Demoted) != 1 || r.Demoted[0].Previous.ThirtyDayStats != 70 {
+		t.Errorf("Got: %v, Wanted: carol demoted from 70 hours", r.Demoted)
+	}
+	want := []summary.Mover{{Name: "dave", From: 4, To: 1}, {Name: "alice", From: 1, To: 3}, {Name: "bob", From: 2, To: 4}}
+	if !reflect.DeepEqual(r.Movers, want) {
+		t.Errorf("Got: %v, Wanted: %v", r.Movers, want)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	prev, cur := run()
+	failures := []summary.Failure{{Name: "bob", Err: errors.New("sullygnome: 500 |\nInternal Server Error")}}
+	r := summary.New(prev, cur, changes.Diff(prev, cur), failures, now)
+
+	got := string(r.Markdown())
+	for _, want := range []string{
+		"## secinfo run 2026-10-19 06:00 UTC\n",
+		"| 4 | 1 | 220.0 | 1 | 1 | 0 | 0 | 1 |\n",
+		"- **erin** streamed 50.0 hours\n",
+		"- **carol** streamed 0.0 hours, down from 70.0\n",
+		"| dave | 4 → 1 | ▲ 3 |\n",
+		"| alice | 1 → 3 | ▼ 2 |\n",
+		"| bob | sullygnome: 500 \\| Internal Server Error |\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Got: %s, Wanted it to contain: %q", got, want)
+		}
+	}
+	if strings.Contains(got, "### Added") {
+		t.Errorf("Got: %s, Wanted no empty sections", got)
+	}
+
+	r.KeptPrevious = true
+	if got := string(r.Markdown()); !strings.Contains(got, "kept their hours from the last run") {
+		t.Errorf("Got: %s, Wanted the failures to explain they kept the last run's hours", got)
+	}
+	r.KeptPrevious = false
+
+	r.Warnings = []string{"fetching bob's schedule, kept their last calendar: twitch /schedule: 503"}
+	if got := string(r.Markdown()); !strings.Contains(got, "### Warnings\n\n- fetching bob's schedule, kept their last calendar: twitch /schedule: 503\n") {
+		t.Errorf("Got: %s, Wanted the warnings listed", got)
+	}
+	r.Warnings = nil
+
+	r.List = "ctf"
+	if got := string(r.Markdown()); !strings.HasPrefix(got, "## secinfo run of ctf 2026-10-19") {
+		t.Errorf("Got: %q, Wanted the list's name in the heading", got)
+	}
+	r.List = ""
+
+	pr := string(r.PullRequest())
+	if !strings.HasPrefix(pr, "Automated update of the streamer lists from the last 30 days") || !strings.Contains(pr, "### Promoted to active") {
+		t.Errorf("Got: %s", pr)
+	}
+}
+
+func TestAppend(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for _, part := range []string{"first\n", "second\n"} {
+		if err := summary.Append(fs, "/tmp/summary.md", []byte(part)); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	got, _ := afero.ReadFile(fs, "/tmp/summary.md")
+	if string(got) != "first\nsecond\n" {
+		t.Errorf("Got: %q, Wanted: %q", got, "first\nsecond\n")
+	}
+}